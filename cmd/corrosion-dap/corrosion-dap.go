@@ -0,0 +1,19 @@
+// corrosion-dap is a Debug Adapter Protocol server for Corrosion programs.  An
+// editor starts it and exchanges protocol messages over standard input and
+// output.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/freddiehaddad/corrosion/pkg/dap"
+)
+
+func main() {
+	server := dap.NewServer(os.Stdin, os.Stdout)
+	if err := server.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
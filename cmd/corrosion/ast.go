@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Parses the script named in args and prints its syntax tree as JSON.
+func printAST(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: corrosion ast file")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	l := lexer.NewReader(f)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	f.Close()
+	if errs := p.Errors(); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	data, err := ast.Marshal(program)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
@@ -1,68 +1,43 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 
-	"github.com/freddiehaddad/corrosion/pkg/ast"
-	"github.com/freddiehaddad/corrosion/pkg/evaluator"
-	"github.com/freddiehaddad/corrosion/pkg/lexer"
-	"github.com/freddiehaddad/corrosion/pkg/object"
-	"github.com/freddiehaddad/corrosion/pkg/parser"
+	"github.com/freddiehaddad/corrosion/pkg/repl"
 )
 
-const (
-	appName = "Corrosion"
-	prompt  = "> "
-)
-
-func evaluate(p *ast.Program, env *object.Environment) {
-	for _, statement := range p.Statements {
-		obj := evaluator.Eval(statement, env)
-		if obj.Type() != object.NULL_OBJ {
-			fmt.Println(obj.Inspect())
-		}
-	}
-}
+const usage = `usage: corrosion [command]
 
-// Returns true if there were any parser errors.
-func checkAndPrintErrors(p *parser.Parser) bool {
-	errors := p.Errors()
-	if len(errors) == 0 {
-		return false
-	}
-
-	fmt.Printf("ParseProgram returned %d errors\n", len(errors))
-	for index, error := range errors {
-		fmt.Printf("errors[%d]: %s\n", index, error)
-	}
+With no command, an interactive session is started.
 
-	return true
-}
+commands:
+  run file    run file (-profile to record where the time is spent)
+  debug file  run file under the debugger
+  ast file    print the syntax tree of file as JSON`
 
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
-	env := object.NewEnvironment()
-
-	fmt.Println("Welcome to", appName)
-	fmt.Println("")
-	fmt.Println("Press Ctrl+D (^D) to exit")
-
-	fmt.Print(prompt)
-	for scanner.Scan() {
-		input := scanner.Text()
-
-		l := lexer.New(input)
-		p := parser.New(l)
-		program := p.ParseProgram()
-
-		if !checkAndPrintErrors(p) {
-			evaluate(program, env)
-		}
+	if len(os.Args) < 2 {
+		repl.Start(os.Stdin, os.Stdout)
+		return
+	}
 
-		fmt.Print(prompt)
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "run":
+		err = run(args)
+	case "debug":
+		err = debug(args)
+	case "ast":
+		err = printAST(args)
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", command, usage)
 	}
 
-	fmt.Println("Exiting", appName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/debugger"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Runs the script named in args under the debugger, reading commands from
+// standard input.
+func debug(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: corrosion debug file")
+	}
+
+	source, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	console := debugger.NewConsole(os.Stdin, os.Stdout, string(source))
+	d := debugger.New(console, true)
+
+	result, err := d.Run(program, object.NewEnvironment())
+	if err != nil {
+		return err
+	}
+
+	if result != nil && result.Type() == object.ERROR_OBJ {
+		fmt.Println(result.Inspect())
+	}
+	fmt.Println("program exited")
+
+	return nil
+}
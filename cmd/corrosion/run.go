@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/optimize"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+	"github.com/freddiehaddad/corrosion/pkg/profile"
+)
+
+// Runs the script named in args.  With -optimize, the program is simplified
+// by the optimize package before it is evaluated.  With -profile, a report of
+// where the time was spent is written to standard error and a pprof profile
+// to a file.
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	optimizing := flags.Bool("optimize", false, "")
+	profiling := flags.Bool("profile", false, "")
+	output := flags.String("profile-out", "corrosion.pprof", "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(
+			"usage: corrosion run [-optimize] [-profile] [-profile-out file] file")
+	}
+	filename := flags.Arg(0)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	l := lexer.NewReader(f)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	f.Close()
+	if errs := p.Errors(); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	if *optimizing {
+		program = optimize.Program(program)
+	}
+
+	var result object.Object
+	if *profiling {
+		result, err = runProfiled(program, filename, *output)
+		if err != nil {
+			return err
+		}
+	} else {
+		result = evaluator.Eval(program, object.NewEnvironment())
+	}
+
+	if result != nil && result.Type() == object.ERROR_OBJ {
+		return errors.New(result.Inspect())
+	}
+
+	return nil
+}
+
+// Evaluates program under the profiler and writes its reports.
+func runProfiled(
+	program *ast.Program, filename, output string,
+) (object.Object, error) {
+	profiler := profile.New(filename)
+	result := profiler.Run(program, object.NewEnvironment())
+
+	if err := profiler.WriteText(os.Stderr); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	if err := profiler.WritePprof(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "\nprofile written to %s\n", output)
+
+	return result, nil
+}
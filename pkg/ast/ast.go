@@ -1,7 +1,9 @@
 package ast
 
 import (
+	"math/big"
 	"strings"
+	"sync"
 
 	"github.com/freddiehaddad/corrosion/pkg/token"
 )
@@ -32,9 +34,16 @@ type Expression interface {
 // AST Nodes
 // ----------------------------------------------------------------------------
 
+// Fields tagged `ast:"-"` are not part of the syntax.  They hold information
+// added by the evaluator, and are left out when a tree is printed or encoded.
+
 // The set of parsed statements representing the program as an AST.
 type Program struct {
 	Statements []Statement
+
+	// Guards the evaluator's resolution of the program's identifiers, which
+	// is done only before the program is first evaluated.
+	Resolution sync.Once `ast:"-"`
 }
 
 func (p *Program) TokenLiteral() string {
@@ -59,6 +68,25 @@ func (p *Program) String() string {
 // Statements
 // ----------------------------------------------------------------------------
 
+// Line returns the line on which s starts, or 0 if it is unknown.
+func Line(s Statement) int {
+	switch s := s.(type) {
+	case *BlockStatement:
+		return s.Token.Line
+	case *ExpressionStatement:
+		return s.Token.Line
+	case *FunctionDeclarationStatement:
+		return s.Token.Line
+	case *IfStatement:
+		return s.Token.Line
+	case *ReturnStatement:
+		return s.Token.Line
+	case *VariableDeclarationStatement:
+		return s.Token.Line
+	}
+	return 0
+}
+
 // { ... }
 type BlockStatement struct {
 	Token      token.Token // the { token
@@ -119,6 +147,9 @@ func (fds *FunctionDeclarationStatement) String() string {
 }
 
 // if (Condition) BlockStatement <else BlockStatement>
+//
+// An IfStatement may also appear in expression position, in which case it
+// yields the value of the last statement in the taken branch.
 type IfStatement struct {
 	Condition   Expression
 	Consequence Statement
@@ -159,13 +190,18 @@ func (rs *ReturnStatement) String() string {
 	return sb.String()
 }
 
-// var Identifier = Expression
+// var Identifier = Expression, or const Identifier = Expression
 type VariableDeclarationStatement struct {
 	Value Expression
 	Name  Identifier
 	Token token.Token
 }
 
+// Constant reports whether the declaration was made with const.
+func (ds *VariableDeclarationStatement) Constant() bool {
+	return ds.Token.Type == token.CONST
+}
+
 func (ds *VariableDeclarationStatement) statementNode() {}
 func (ds *VariableDeclarationStatement) TokenLiteral() string {
 	return ds.Token.Literal
@@ -186,7 +222,8 @@ func (ds *VariableDeclarationStatement) String() string {
 // Expressions
 // ----------------------------------------------------------------------------
 
-// Identifier = Expression
+// Identifier = Expression, or a compound assignment such as Identifier +=
+// Expression
 type AssignmentExpression struct {
 	Token    token.Token
 	Left     Expression
@@ -208,6 +245,31 @@ func (a *AssignmentExpression) String() string {
 	return sb.String()
 }
 
+// Condition ? Consequence : Alternative
+type ConditionalExpression struct {
+	Token       token.Token // the ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (c *ConditionalExpression) expressionNode() {}
+func (c *ConditionalExpression) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *ConditionalExpression) String() string {
+	sb := strings.Builder{}
+	sb.WriteByte('(')
+	sb.WriteString(c.Condition.String())
+	sb.WriteString(" ? ")
+	sb.WriteString(c.Consequence.String())
+	sb.WriteString(" : ")
+	sb.WriteString(c.Alternative.String())
+	sb.WriteByte(')')
+	return sb.String()
+}
+
 // Identifier(Arguments)
 type FunctionCallExpression struct {
 	Token     token.Token
@@ -260,6 +322,72 @@ func (i *InfixExpression) String() string {
 	return sb.String()
 }
 
+// Expression Op, where Op is ++ or --
+type PostfixExpression struct {
+	Left     Expression
+	Token    token.Token
+	Operator string
+}
+
+func (p *PostfixExpression) expressionNode()      {}
+func (p *PostfixExpression) TokenLiteral() string { return p.Token.Literal }
+func (p *PostfixExpression) String() string {
+	sb := strings.Builder{}
+	sb.WriteByte('(')
+	sb.WriteString(p.Left.String())
+	sb.WriteString(p.Operator)
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// match (Subject) { Pattern <if Guard> => Body, ... }
+type MatchExpression struct {
+	Token   token.Token // the match token
+	Subject Expression
+	Arms    []MatchArm
+}
+
+func (m *MatchExpression) expressionNode()      {}
+func (m *MatchExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchExpression) String() string {
+	var sb strings.Builder
+	sb.WriteString("match (")
+	sb.WriteString(m.Subject.String())
+	sb.WriteString(") { ")
+
+	sep := ""
+	for _, arm := range m.Arms {
+		sb.WriteString(sep)
+		sep = ", "
+		sb.WriteString(arm.String())
+	}
+
+	sb.WriteString(" }")
+	return sb.String()
+}
+
+// A single arm of a match expression.  Pattern is a literal, an Identifier
+// binding the matched value, or a Wildcard.  Guard is nil when the arm has no
+// if clause.  Body is an ExpressionStatement or a BlockStatement.
+type MatchArm struct {
+	Pattern Expression
+	Guard   Expression
+	Body    Statement
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Pattern.TokenLiteral() }
+func (ma *MatchArm) String() string {
+	var sb strings.Builder
+	sb.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		sb.WriteString(" if ")
+		sb.WriteString(ma.Guard.String())
+	}
+	sb.WriteString(" => ")
+	sb.WriteString(ma.Body.String())
+	return sb.String()
+}
+
 // Prefix Expression
 type PrefixExpression struct {
 	Right    Expression
@@ -294,17 +422,51 @@ func (b *Boolean) String() string       { return b.Token.Literal }
 type Identifier struct {
 	Token token.Token
 	Value string
+
+	// Where the binding the identifier refers to is expected to be found at
+	// run time: Depth scopes out from the current one, at position Index in
+	// that scope.  Set by the evaluator's resolver, with a Depth of -1 for
+	// identifiers bound outside of the program.
+	Depth int `ast:"-"`
+	Index int `ast:"-"`
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
+// _ pattern matching any value
+type Wildcard struct {
+	Token token.Token
+}
+
+func (w *Wildcard) expressionNode()      {}
+func (w *Wildcard) TokenLiteral() string { return w.Token.Literal }
+func (w *Wildcard) String() string       { return w.Token.Literal }
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+
+	// The object the literal evaluates to, created by the evaluator's
+	// resolver so that evaluating the literal does not allocate.
+	Object any `ast:"-"`
 }
 
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
+
+// Integer literal too large to be represented by an int64.
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+
+	// The object the literal evaluates to, created by the evaluator's
+	// resolver.
+	Object any `ast:"-"`
+}
+
+func (b *BigIntegerLiteral) expressionNode()      {}
+func (b *BigIntegerLiteral) TokenLiteral() string { return b.Token.Literal }
+func (b *BigIntegerLiteral) String() string       { return b.Token.Literal }
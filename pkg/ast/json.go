@@ -0,0 +1,519 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/freddiehaddad/corrosion/pkg/token"
+)
+
+// ----------------------------------------------------------------------------
+// JSON encoding
+// ----------------------------------------------------------------------------
+
+// jsonNode is the JSON form of every node type.  Kind names the node type and
+// selects which of the other fields are used; those that do not apply to the
+// kind are omitted.  Line and Column give the position of the node's token,
+// which for operators is the operator rather than the first operand.
+type jsonNode struct {
+	Kind   string     `json:"kind"`
+	Line   int        `json:"line,omitempty"`
+	Column int        `json:"column,omitempty"`
+	Token  *jsonToken `json:"token,omitempty"`
+
+	Operator string `json:"operator,omitempty"`
+
+	Name        *jsonNode   `json:"name,omitempty"`
+	Parameters  []*jsonNode `json:"parameters,omitempty"`
+	Statements  []*jsonNode `json:"statements,omitempty"`
+	Expression  *jsonNode   `json:"expression,omitempty"`
+	Condition   *jsonNode   `json:"condition,omitempty"`
+	Consequence *jsonNode   `json:"consequence,omitempty"`
+	Alternative *jsonNode   `json:"alternative,omitempty"`
+	Function    *jsonNode   `json:"function,omitempty"`
+	Arguments   []*jsonNode `json:"arguments,omitempty"`
+	Left        *jsonNode   `json:"left,omitempty"`
+	Right       *jsonNode   `json:"right,omitempty"`
+	Subject     *jsonNode   `json:"subject,omitempty"`
+	Arms        []*jsonNode `json:"arms,omitempty"`
+	Pattern     *jsonNode   `json:"pattern,omitempty"`
+	Guard       *jsonNode   `json:"guard,omitempty"`
+	Body        *jsonNode   `json:"body,omitempty"`
+
+	Value json.RawMessage `json:"value,omitempty"` // a node or a literal
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+}
+
+// Marshal returns the JSON encoding of the tree rooted at node.  Each node is
+// an object with a "kind" member naming its type, e.g. "InfixExpression", the
+// "line" and "column" of its token and the token itself.  Unmarshal decodes
+// the result into an equivalent tree.
+func Marshal(node Node) ([]byte, error) {
+	j, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// Unmarshal decodes a tree encoded by Marshal and returns its root.
+func Unmarshal(data []byte) (Node, error) {
+	var j jsonNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return decode(&j)
+}
+
+// Returns a jsonNode for node with the position and literal of t.
+func newJSONNode(node Node, t token.Token) *jsonNode {
+	return &jsonNode{
+		Kind:   kind(node),
+		Line:   t.Line,
+		Column: t.Column,
+		Token:  &jsonToken{Type: t.Type, Literal: t.Literal},
+	}
+}
+
+// Returns the name of the type of node, without the package.
+func kind(node Node) string {
+	name := fmt.Sprintf("%T", node)
+	return name[len("*ast."):]
+}
+
+func encode(node Node) (*jsonNode, error) {
+	var j *jsonNode
+	var err error
+
+	// records the first error encoding a child
+	child := func(n Node) *jsonNode {
+		if n == nil || err != nil {
+			return nil
+		}
+		var c *jsonNode
+		c, err = encode(n)
+		return c
+	}
+	children := func(nodes []Node) []*jsonNode {
+		list := make([]*jsonNode, 0, len(nodes))
+		for _, n := range nodes {
+			list = append(list, child(n))
+		}
+		return list
+	}
+	value := func(v any) json.RawMessage {
+		if err != nil {
+			return nil
+		}
+		var data []byte
+		data, err = json.Marshal(v)
+		return data
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		j = &jsonNode{Kind: kind(n)}
+		j.Statements = children(statementNodes(n.Statements))
+
+	// statements
+	case *BlockStatement:
+		j = newJSONNode(n, n.Token)
+		j.Statements = children(statementNodes(n.Statements))
+	case *ExpressionStatement:
+		j = newJSONNode(n, n.Token)
+		j.Expression = child(n.Expression)
+	case *FunctionDeclarationStatement:
+		j = newJSONNode(n, n.Token)
+		j.Name = child(&n.Name)
+		j.Parameters = children(identifierNodes(n.Parameters))
+		j.Body = child(n.Body)
+	case *IfStatement:
+		j = newJSONNode(n, n.Token)
+		j.Condition = child(n.Condition)
+		j.Consequence = child(n.Consequence)
+		j.Alternative = child(n.Alternative)
+	case *ReturnStatement:
+		j = newJSONNode(n, n.Token)
+		if n.ReturnValue != nil {
+			j.Value = value(child(n.ReturnValue))
+		}
+	case *VariableDeclarationStatement:
+		j = newJSONNode(n, n.Token)
+		j.Name = child(&n.Name)
+		if n.Value != nil {
+			j.Value = value(child(n.Value))
+		}
+
+	// expressions
+	case *AssignmentExpression:
+		j = newJSONNode(n, n.Token)
+		j.Operator = n.Operator
+		j.Left = child(n.Left)
+		j.Right = child(n.Right)
+	case *ConditionalExpression:
+		j = newJSONNode(n, n.Token)
+		j.Condition = child(n.Condition)
+		j.Consequence = child(n.Consequence)
+		j.Alternative = child(n.Alternative)
+	case *FunctionCallExpression:
+		j = newJSONNode(n, n.Token)
+		j.Function = child(n.Function)
+		j.Arguments = children(expressionNodes(n.Arguments))
+	case *InfixExpression:
+		j = newJSONNode(n, n.Token)
+		j.Operator = n.Operator
+		j.Left = child(n.Left)
+		j.Right = child(n.Right)
+	case *PostfixExpression:
+		j = newJSONNode(n, n.Token)
+		j.Operator = n.Operator
+		j.Left = child(n.Left)
+	case *MatchExpression:
+		j = newJSONNode(n, n.Token)
+		j.Subject = child(n.Subject)
+		for i := range n.Arms {
+			j.Arms = append(j.Arms, child(&n.Arms[i]))
+		}
+	case *MatchArm:
+		j = &jsonNode{Kind: kind(n)}
+		j.Pattern = child(n.Pattern)
+		j.Guard = child(n.Guard)
+		j.Body = child(n.Body)
+	case *PrefixExpression:
+		j = newJSONNode(n, n.Token)
+		j.Operator = n.Operator
+		j.Right = child(n.Right)
+
+	// basic types
+	case *Boolean:
+		j = newJSONNode(n, n.Token)
+		j.Value = value(n.Value)
+	case *Identifier:
+		j = newJSONNode(n, n.Token)
+		j.Value = value(n.Value)
+	case *Wildcard:
+		j = newJSONNode(n, n.Token)
+	case *IntegerLiteral:
+		j = newJSONNode(n, n.Token)
+		j.Value = value(n.Value)
+	case *BigIntegerLiteral:
+		j = newJSONNode(n, n.Token)
+		if n.Value != nil {
+			j.Value = value(n.Value.String())
+		}
+
+	default:
+		return nil, fmt.Errorf("ast.Marshal: unexpected node type %T", n)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func statementNodes(statements []Statement) []Node {
+	nodes := make([]Node, len(statements))
+	for i, s := range statements {
+		nodes[i] = s
+	}
+	return nodes
+}
+
+func expressionNodes(expressions []Expression) []Node {
+	nodes := make([]Node, len(expressions))
+	for i, e := range expressions {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+func identifierNodes(identifiers []Identifier) []Node {
+	nodes := make([]Node, len(identifiers))
+	for i := range identifiers {
+		nodes[i] = &identifiers[i]
+	}
+	return nodes
+}
+
+// ----------------------------------------------------------------------------
+// JSON decoding
+// ----------------------------------------------------------------------------
+
+// A decoder converts jsonNodes back into nodes, keeping the first error.
+type decoder struct {
+	err error
+}
+
+func decode(j *jsonNode) (Node, error) {
+	d := &decoder{}
+	node := d.node(j)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast.Unmarshal: "+format, args...)
+	}
+}
+
+// Returns the token of j, with its position.
+func (d *decoder) token(j *jsonNode) token.Token {
+	if j.Token == nil {
+		d.fail("%s has no token", j.Kind)
+		return token.Token{}
+	}
+	return token.Token{
+		Type:    j.Token.Type,
+		Literal: j.Token.Literal,
+		Line:    j.Line,
+		Column:  j.Column,
+	}
+}
+
+// Decodes the literal held in the value of j into v.
+func (d *decoder) literal(j *jsonNode, v any) {
+	if len(j.Value) == 0 {
+		d.fail("%s has no value", j.Kind)
+		return
+	}
+	if err := json.Unmarshal(j.Value, v); err != nil {
+		d.fail("%s value: %v", j.Kind, err)
+	}
+}
+
+// Decodes the node held in the value of j, which may be absent.
+func (d *decoder) valueNode(j *jsonNode) *jsonNode {
+	if len(j.Value) == 0 {
+		return nil
+	}
+	var v jsonNode
+	if err := json.Unmarshal(j.Value, &v); err != nil {
+		d.fail("%s value: %v", j.Kind, err)
+		return nil
+	}
+	return &v
+}
+
+// Returns child, the member named field of j, failing if it is absent.
+func (d *decoder) need(j, child *jsonNode, field string) *jsonNode {
+	if child == nil {
+		d.fail("%s has no %s", j.Kind, field)
+	}
+	return child
+}
+
+func (d *decoder) statement(j *jsonNode) Statement {
+	if j == nil {
+		return nil
+	}
+	node := d.node(j)
+	if node == nil {
+		return nil
+	}
+	s, ok := node.(Statement)
+	if !ok {
+		d.fail("%s is not a statement", j.Kind)
+	}
+	return s
+}
+
+func (d *decoder) statements(list []*jsonNode) []Statement {
+	statements := []Statement{}
+	for _, j := range list {
+		if j == nil {
+			d.fail("null statement")
+			return nil
+		}
+		statements = append(statements, d.statement(j))
+	}
+	return statements
+}
+
+func (d *decoder) expression(j *jsonNode) Expression {
+	if j == nil {
+		return nil
+	}
+	node := d.node(j)
+	if node == nil {
+		return nil
+	}
+	e, ok := node.(Expression)
+	if !ok {
+		d.fail("%s is not an expression", j.Kind)
+	}
+	return e
+}
+
+func (d *decoder) identifier(j *jsonNode) Identifier {
+	if j == nil {
+		d.fail("missing identifier")
+		return Identifier{}
+	}
+	i, ok := d.node(j).(*Identifier)
+	if !ok {
+		d.fail("%s is not an Identifier", j.Kind)
+		return Identifier{}
+	}
+	return *i
+}
+
+func (d *decoder) node(j *jsonNode) Node {
+	if d.err != nil {
+		return nil
+	}
+
+	switch j.Kind {
+	case "Program":
+		return &Program{Statements: d.statements(j.Statements)}
+
+	// statements
+	case "BlockStatement":
+		return &BlockStatement{
+			Token:      d.token(j),
+			Statements: d.statements(j.Statements),
+		}
+	case "ExpressionStatement":
+		return &ExpressionStatement{
+			Token:      d.token(j),
+			Expression: d.expression(d.need(j, j.Expression, "expression")),
+		}
+	case "FunctionDeclarationStatement":
+		n := &FunctionDeclarationStatement{
+			Token: d.token(j),
+			Name:  d.identifier(j.Name),
+			Body:  d.statement(d.need(j, j.Body, "body")),
+		}
+		for _, p := range j.Parameters {
+			n.Parameters = append(n.Parameters, d.identifier(p))
+		}
+		return n
+	case "IfStatement":
+		return &IfStatement{
+			Token:       d.token(j),
+			Condition:   d.expression(d.need(j, j.Condition, "condition")),
+			Consequence: d.statement(d.need(j, j.Consequence, "consequence")),
+			Alternative: d.statement(j.Alternative),
+		}
+	case "ReturnStatement":
+		return &ReturnStatement{
+			Token:       d.token(j),
+			ReturnValue: d.expression(d.valueNode(j)),
+		}
+	case "VariableDeclarationStatement":
+		return &VariableDeclarationStatement{
+			Token: d.token(j),
+			Name:  d.identifier(j.Name),
+			Value: d.expression(d.valueNode(j)),
+		}
+
+	// expressions
+	case "AssignmentExpression":
+		return &AssignmentExpression{
+			Token:    d.token(j),
+			Operator: j.Operator,
+			Left:     d.expression(d.need(j, j.Left, "left")),
+			Right:    d.expression(d.need(j, j.Right, "right")),
+		}
+	case "ConditionalExpression":
+		return &ConditionalExpression{
+			Token:       d.token(j),
+			Condition:   d.expression(d.need(j, j.Condition, "condition")),
+			Consequence: d.expression(d.need(j, j.Consequence, "consequence")),
+			Alternative: d.expression(d.need(j, j.Alternative, "alternative")),
+		}
+	case "FunctionCallExpression":
+		n := &FunctionCallExpression{
+			Token:    d.token(j),
+			Function: d.expression(d.need(j, j.Function, "function")),
+		}
+		for _, a := range j.Arguments {
+			argument := d.expression(d.need(j, a, "argument"))
+			n.Arguments = append(n.Arguments, argument)
+		}
+		return n
+	case "InfixExpression":
+		return &InfixExpression{
+			Token:    d.token(j),
+			Operator: j.Operator,
+			Left:     d.expression(d.need(j, j.Left, "left")),
+			Right:    d.expression(d.need(j, j.Right, "right")),
+		}
+	case "PostfixExpression":
+		return &PostfixExpression{
+			Token:    d.token(j),
+			Operator: j.Operator,
+			Left:     d.expression(d.need(j, j.Left, "left")),
+		}
+	case "MatchExpression":
+		n := &MatchExpression{
+			Token:   d.token(j),
+			Subject: d.expression(d.need(j, j.Subject, "subject")),
+		}
+		for _, a := range j.Arms {
+			if d.need(j, a, "arm") == nil {
+				return nil
+			}
+			arm, ok := d.node(a).(*MatchArm)
+			if !ok {
+				d.fail("%s is not a MatchArm", a.Kind)
+				return nil
+			}
+			n.Arms = append(n.Arms, *arm)
+		}
+		return n
+	case "MatchArm":
+		return &MatchArm{
+			Pattern: d.expression(d.need(j, j.Pattern, "pattern")),
+			Guard:   d.expression(j.Guard),
+			Body:    d.statement(d.need(j, j.Body, "body")),
+		}
+	case "PrefixExpression":
+		return &PrefixExpression{
+			Token:    d.token(j),
+			Operator: j.Operator,
+			Right:    d.expression(d.need(j, j.Right, "right")),
+		}
+
+	// basic types
+	case "Boolean":
+		n := &Boolean{Token: d.token(j)}
+		d.literal(j, &n.Value)
+		return n
+	case "Identifier":
+		n := &Identifier{Token: d.token(j)}
+		d.literal(j, &n.Value)
+		return n
+	case "Wildcard":
+		return &Wildcard{Token: d.token(j)}
+	case "IntegerLiteral":
+		n := &IntegerLiteral{Token: d.token(j)}
+		d.literal(j, &n.Value)
+		return n
+	case "BigIntegerLiteral":
+		n := &BigIntegerLiteral{Token: d.token(j)}
+		var s string
+		d.literal(j, &s)
+		value, ok := new(big.Int).SetString(s, 10)
+		if !ok && d.err == nil {
+			d.fail("invalid BigIntegerLiteral value %q", s)
+		}
+		n.Value = value
+		return n
+	}
+
+	if j.Kind == "" {
+		d.fail("node has no kind")
+	} else {
+		d.fail("unknown node kind %q", j.Kind)
+	}
+	return nil
+}
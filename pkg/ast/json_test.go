@@ -0,0 +1,207 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		program,
+		"",
+		"var x = 0xFF; const y = -x;",
+		"x += 2; x--; !true;",
+		"func f() { }",
+		"func g(a, b, c) { if (a) { b; } return c; }",
+		"if (1 < 2) { 10 } else { if (false) { 20 } }",
+		"var v = if (x) { 1 } else { 2 };",
+		"var m = match (x) { 0 => 1, n if n % 2 == 0 => { n / 2 }, _ => 3 };",
+		"f(1, g(2), h)(3);",
+		"a || b && c == d ? e ^ ~1 : f ** 2 >> 1;",
+		"{ var z = 340282366920938463463374607431768211456; z; }",
+		"var größe = 1_000;",
+	}
+
+	for index, test := range tests {
+		p := parse(t, test)
+
+		data, err := ast.Marshal(p)
+		if err != nil {
+			t.Fatalf("tests[%d]: Marshal failed: %v", index, err)
+		}
+
+		node, err := ast.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("tests[%d]: Unmarshal failed: %v", index, err)
+		}
+
+		if node.String() != p.String() {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, p.String(), node.String())
+		}
+
+		// encoding the decoded tree gives the same JSON
+		again, err := ast.Marshal(node)
+		if err != nil {
+			t.Fatalf("tests[%d]: Marshal failed: %v", index, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("tests[%d]: expected\n%s\ngot=\n%s", index, data, again)
+		}
+	}
+}
+
+func TestJSONTreeEqual(t *testing.T) {
+	p := parse(t, program)
+
+	data, err := ast.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := ast.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compare a node at a time, since the parser leaves some empty lists nil
+	// and others empty
+	expected, got := []ast.Node{}, []ast.Node{}
+	ast.Inspect(p, func(n ast.Node) bool {
+		expected = append(expected, n)
+		return true
+	})
+	ast.Inspect(node, func(n ast.Node) bool {
+		got = append(got, n)
+		return true
+	})
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d nodes got=%d", len(expected), len(got))
+	}
+	for index := range expected {
+		if reflect.TypeOf(got[index]) != reflect.TypeOf(expected[index]) {
+			t.Fatalf("tests[%d]: expected %T got=%T",
+				index, expected[index], got[index])
+		}
+		if got[index] != nil && got[index].String() != expected[index].String() {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, expected[index], got[index])
+		}
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, err := ast.Marshal(parse(t, "x\n  + 1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tree map[string]any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree["kind"] != "Program" {
+		t.Fatalf("expected kind Program got=%v", tree["kind"])
+	}
+
+	statement := tree["statements"].([]any)[0].(map[string]any)
+	infix := statement["expression"].(map[string]any)
+
+	tests := []struct {
+		key      string
+		expected any
+	}{
+		{"kind", "InfixExpression"},
+		{"line", 2.0},
+		{"column", 3.0},
+		{"operator", "+"},
+	}
+
+	for index, test := range tests {
+		if infix[test.key] != test.expected {
+			t.Errorf("tests[%d]: expected %s=%v got=%v",
+				index, test.key, test.expected, infix[test.key])
+		}
+	}
+
+	right := infix["right"].(map[string]any)
+	if right["kind"] != "IntegerLiteral" || right["value"] != 1.0 {
+		t.Errorf("unexpected right operand %v", right)
+	}
+}
+
+func TestJSONUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, "node has no kind"},
+		{`{"kind": "Loop"}`, `unknown node kind "Loop"`},
+		{`{"kind": "Program", "statements": [{"kind": "Identifier",
+			"token": {"type": "IDENT", "literal": "x"}, "value": "x"}]}`,
+			"Identifier is not a statement"},
+		{`{"kind": "ExpressionStatement"}`, "ExpressionStatement has no token"},
+		{`{"kind": "IntegerLiteral", "token": {}, "value": "one"}`,
+			"IntegerLiteral value"},
+		{`[]`, "cannot unmarshal"},
+
+		// missing and null children
+		{`{"kind": "Program", "statements": [null]}`, "null statement"},
+		{`{"kind": "InfixExpression", "operator": "+",
+			"token": {"type": "+", "literal": "+"}}`,
+			"InfixExpression has no left"},
+		{`{"kind": "InfixExpression", "operator": "+",
+			"token": {"type": "+", "literal": "+"},
+			"left": {"kind": "Identifier",
+				"token": {"type": "IDENT", "literal": "x"}, "value": "x"}}`,
+			"InfixExpression has no right"},
+		{`{"kind": "MatchExpression", "token": {"type": "MATCH"},
+			"subject": {"kind": "Identifier",
+				"token": {"type": "IDENT", "literal": "x"}, "value": "x"},
+			"arms": [null]}`,
+			"MatchExpression has no arm"},
+		{`{"kind": "MatchExpression", "token": {"type": "MATCH"}}`,
+			"MatchExpression has no subject"},
+		{`{"kind": "MatchArm", "pattern": {"kind": "Wildcard",
+			"token": {"type": "_"}}}`,
+			"MatchArm has no body"},
+		{`{"kind": "IfStatement", "token": {"type": "IF"}}`,
+			"IfStatement has no condition"},
+		{`{"kind": "ConditionalExpression", "token": {"type": "?"},
+			"condition": {"kind": "Boolean", "token": {}, "value": true},
+			"consequence": {"kind": "Boolean", "token": {}, "value": true}}`,
+			"ConditionalExpression has no alternative"},
+		{`{"kind": "FunctionCallExpression", "token": {"type": "("}}`,
+			"FunctionCallExpression has no function"},
+		{`{"kind": "FunctionCallExpression", "token": {"type": "("},
+			"function": {"kind": "Identifier",
+				"token": {"type": "IDENT", "literal": "f"}, "value": "f"},
+			"arguments": [null]}`,
+			"FunctionCallExpression has no argument"},
+		{`{"kind": "FunctionDeclarationStatement", "token": {"type": "FUNC"},
+			"name": {"kind": "Identifier",
+				"token": {"type": "IDENT", "literal": "f"}, "value": "f"}}`,
+			"FunctionDeclarationStatement has no body"},
+		{`{"kind": "ExpressionStatement", "token": {}}`,
+			"ExpressionStatement has no expression"},
+		{`{"kind": "PrefixExpression", "token": {"type": "-"}}`,
+			"PrefixExpression has no right"},
+		{`{"kind": "AssignmentExpression", "token": {"type": "="}}`,
+			"AssignmentExpression has no left"},
+		{`{"kind": "PostfixExpression", "token": {"type": "++"}}`,
+			"PostfixExpression has no left"},
+	}
+
+	for index, test := range tests {
+		_, err := ast.Unmarshal([]byte(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("tests[%d]: expected error containing %q got=%v",
+				index, test.expected, err)
+		}
+	}
+}
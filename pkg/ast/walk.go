@@ -0,0 +1,271 @@
+package ast
+
+import "fmt"
+
+// ----------------------------------------------------------------------------
+// Walk
+// ----------------------------------------------------------------------------
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.  If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil.  If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor w for
+// each of the non-nil children of node, followed by a call of w.Visit(nil).
+//
+// Children are visited in source order.  Identifiers held by value, such as
+// the name and parameters of a function declaration, are visited as
+// *Identifier, and the arms of a match expression as *MatchArm.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	// statements
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *ExpressionStatement:
+		walkOptional(v, n.Expression)
+	case *FunctionDeclarationStatement:
+		Walk(v, &n.Name)
+		for i := range n.Parameters {
+			Walk(v, &n.Parameters[i])
+		}
+		walkOptional(v, n.Body)
+	case *IfStatement:
+		walkOptional(v, n.Condition)
+		walkOptional(v, n.Consequence)
+		walkOptional(v, n.Alternative)
+	case *ReturnStatement:
+		walkOptional(v, n.ReturnValue)
+	case *VariableDeclarationStatement:
+		Walk(v, &n.Name)
+		walkOptional(v, n.Value)
+
+	// expressions
+	case *AssignmentExpression:
+		walkOptional(v, n.Left)
+		walkOptional(v, n.Right)
+	case *ConditionalExpression:
+		walkOptional(v, n.Condition)
+		walkOptional(v, n.Consequence)
+		walkOptional(v, n.Alternative)
+	case *FunctionCallExpression:
+		walkOptional(v, n.Function)
+		for _, argument := range n.Arguments {
+			walkOptional(v, argument)
+		}
+	case *InfixExpression:
+		walkOptional(v, n.Left)
+		walkOptional(v, n.Right)
+	case *PostfixExpression:
+		walkOptional(v, n.Left)
+	case *MatchExpression:
+		walkOptional(v, n.Subject)
+		for i := range n.Arms {
+			Walk(v, &n.Arms[i])
+		}
+	case *MatchArm:
+		walkOptional(v, n.Pattern)
+		walkOptional(v, n.Guard)
+		walkOptional(v, n.Body)
+	case *PrefixExpression:
+		walkOptional(v, n.Right)
+
+	// basic types
+	case *Boolean, *Identifier, *Wildcard, *IntegerLiteral,
+		*BigIntegerLiteral:
+		// no children
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, s := range statements {
+		walkOptional(v, s)
+	}
+}
+
+// Walks node unless it is nil, as optional children (and the children of
+// nodes built from invalid input) may be.
+func walkOptional(v Visitor, node Node) {
+	if node != nil {
+		Walk(v, node)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil.  If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call
+// of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// ----------------------------------------------------------------------------
+// Rewrite
+// ----------------------------------------------------------------------------
+
+// Rewrite traverses an AST in depth-first order, replacing each node with the
+// result of calling f on it, and returns the result for node.  The children
+// of a node are rewritten before f is called on the node itself, so f sees
+// them already replaced.
+//
+// A replacement must fit the field that holds it: a Statement where a
+// statement is expected, an Expression where an expression is expected, an
+// *Identifier for identifiers held by value and a *MatchArm for match arms.
+// Rewrite panics otherwise.  Returning nil removes a statement from a program
+// or block; elsewhere it leaves the field nil, which is only valid for
+// optional fields such as the Alternative of an IfStatement.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = rewriteStatements(n.Statements, f)
+
+	// statements
+	case *BlockStatement:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *ExpressionStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+	case *FunctionDeclarationStatement:
+		rewriteIdentifier(&n.Name, f)
+		for i := range n.Parameters {
+			rewriteIdentifier(&n.Parameters[i], f)
+		}
+		n.Body = rewriteStatement(n.Body, f)
+	case *IfStatement:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Consequence = rewriteStatement(n.Consequence, f)
+		n.Alternative = rewriteStatement(n.Alternative, f)
+	case *ReturnStatement:
+		n.ReturnValue = rewriteExpression(n.ReturnValue, f)
+	case *VariableDeclarationStatement:
+		rewriteIdentifier(&n.Name, f)
+		n.Value = rewriteExpression(n.Value, f)
+
+	// expressions
+	case *AssignmentExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Right = rewriteExpression(n.Right, f)
+	case *ConditionalExpression:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Consequence = rewriteExpression(n.Consequence, f)
+		n.Alternative = rewriteExpression(n.Alternative, f)
+	case *FunctionCallExpression:
+		n.Function = rewriteExpression(n.Function, f)
+		for i, argument := range n.Arguments {
+			n.Arguments[i] = rewriteExpression(argument, f)
+		}
+	case *InfixExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Right = rewriteExpression(n.Right, f)
+	case *PostfixExpression:
+		n.Left = rewriteExpression(n.Left, f)
+	case *MatchExpression:
+		n.Subject = rewriteExpression(n.Subject, f)
+		for i := range n.Arms {
+			arm, ok := Rewrite(&n.Arms[i], f).(*MatchArm)
+			if !ok {
+				panic("ast.Rewrite: match arm replaced by a different node")
+			}
+			n.Arms[i] = *arm
+		}
+	case *MatchArm:
+		n.Pattern = rewriteExpression(n.Pattern, f)
+		n.Guard = rewriteExpression(n.Guard, f)
+		n.Body = rewriteStatement(n.Body, f)
+	case *PrefixExpression:
+		n.Right = rewriteExpression(n.Right, f)
+
+	// basic types
+	case *Boolean, *Identifier, *Wildcard, *IntegerLiteral,
+		*BigIntegerLiteral:
+		// no children
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+// Rewrites each statement, dropping those replaced by nil.
+func rewriteStatements(statements []Statement, f func(Node) Node) []Statement {
+	result := statements[:0]
+	for _, s := range statements {
+		if s = rewriteStatement(s, f); s != nil {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func rewriteStatement(s Statement, f func(Node) Node) Statement {
+	if s == nil {
+		return nil
+	}
+
+	node := Rewrite(s, f)
+	if node == nil {
+		return nil
+	}
+
+	statement, ok := node.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T is not a Statement", node))
+	}
+	return statement
+}
+
+func rewriteExpression(e Expression, f func(Node) Node) Expression {
+	if e == nil {
+		return nil
+	}
+
+	node := Rewrite(e, f)
+	if node == nil {
+		return nil
+	}
+
+	expression, ok := node.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T is not an Expression", node))
+	}
+	return expression
+}
+
+// Rewrites an identifier held by value, which can only be replaced by another
+// identifier.
+func rewriteIdentifier(i *Identifier, f func(Node) Node) {
+	identifier, ok := Rewrite(i, f).(*Identifier)
+	if !ok {
+		panic("ast.Rewrite: identifier replaced by a different node")
+	}
+	*i = *identifier
+}
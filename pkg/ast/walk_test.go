@@ -0,0 +1,212 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// A program using every node type.
+const program = `var x = 1;
+const big = 9223372036854775808;
+func f(a, b) {
+  if (a > b) { return a; } else { return -b; }
+}
+x = f(x, 2) ? true : false;
+x++;
+match (x) { 1 => 1, n if n > 1 => { n; }, _ => 0 };`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	return program
+}
+
+// Returns the types of the nodes visited by Inspect, in order.
+func types(node ast.Node) []string {
+	visited := []string{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			visited = append(visited, strings.TrimPrefix(
+				fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+	return visited
+}
+
+func TestInspectOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var x = 1 + y;", "Program VariableDeclarationStatement " +
+			"Identifier InfixExpression IntegerLiteral Identifier"},
+		{"f(a, -b);", "Program ExpressionStatement FunctionCallExpression " +
+			"Identifier Identifier PrefixExpression Identifier"},
+		{"func g(p) { return p; }", "Program FunctionDeclarationStatement " +
+			"Identifier Identifier BlockStatement ReturnStatement Identifier"},
+		{"match (v) { _ if ok => 1 };", "Program ExpressionStatement " +
+			"MatchExpression Identifier MatchArm Wildcard Identifier " +
+			"ExpressionStatement IntegerLiteral"},
+	}
+
+	for index, test := range tests {
+		got := strings.Join(types(parse(t, test.input)), " ")
+		if got != test.expected {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, test.expected, got)
+		}
+	}
+}
+
+func TestInspectCoversEveryNode(t *testing.T) {
+	expected := []string{
+		"Program", "BlockStatement", "ExpressionStatement",
+		"FunctionDeclarationStatement", "IfStatement", "ReturnStatement",
+		"VariableDeclarationStatement", "AssignmentExpression",
+		"ConditionalExpression", "FunctionCallExpression", "InfixExpression",
+		"PostfixExpression", "MatchExpression", "MatchArm",
+		"PrefixExpression", "Boolean", "Identifier", "Wildcard",
+		"IntegerLiteral", "BigIntegerLiteral",
+	}
+
+	visited := map[string]bool{}
+	for _, name := range types(parse(t, program)) {
+		visited[name] = true
+	}
+
+	for index, name := range expected {
+		if !visited[name] {
+			t.Errorf("tests[%d]: %s was not visited", index, name)
+		}
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	identifiers := []string{}
+	ast.Inspect(parse(t, program), func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionDeclarationStatement:
+			return false
+		case *ast.Identifier:
+			identifiers = append(identifiers, n.Value)
+		}
+		return true
+	})
+
+	expected := "x big x f x x x n n n"
+	if got := strings.Join(identifiers, " "); got != expected {
+		t.Errorf("expected %q got=%q", expected, got)
+	}
+}
+
+// Counts the calls of Visit with and without a node.
+type counter struct {
+	nodes, ends int
+}
+
+func (c *counter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		c.ends++
+	} else {
+		c.nodes++
+	}
+	return c
+}
+
+func TestWalkEndsEveryNode(t *testing.T) {
+	c := &counter{}
+	ast.Walk(c, parse(t, program))
+
+	if c.nodes == 0 || c.nodes != c.ends {
+		t.Errorf("expected a Visit(nil) for each node. got=%d nodes, %d ends",
+			c.nodes, c.ends)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	input := `var x = 1 + 2;
+func f(x) { return x * (3 + 4); }
+x++;
+f(x);`
+
+	result := ast.Rewrite(parse(t, input), func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			if n.Value == "x" {
+				return &ast.Identifier{Token: n.Token, Value: "y"}
+			}
+		case *ast.InfixExpression:
+			// fold additions of literals
+			left, lok := n.Left.(*ast.IntegerLiteral)
+			right, rok := n.Right.(*ast.IntegerLiteral)
+			if lok && rok && n.Operator == "+" {
+				value := left.Value + right.Value
+				folded := &ast.IntegerLiteral{Token: left.Token, Value: value}
+				folded.Token.Literal = fmt.Sprint(value)
+				return folded
+			}
+		case *ast.ExpressionStatement:
+			// remove statements that only increment
+			if _, ok := n.Expression.(*ast.PostfixExpression); ok {
+				return nil
+			}
+		}
+		return n
+	})
+
+	expected := "var y = 3;func (y) return (y * 7);f(y)"
+	if got := result.String(); got != expected {
+		t.Errorf("expected %q got=%q", expected, got)
+	}
+
+	fds := result.(*ast.Program).Statements[1].(*ast.FunctionDeclarationStatement)
+	if fds.Name.Value != "f" {
+		t.Errorf("expected the function name f. got=%q", fds.Name.Value)
+	}
+}
+
+func TestRewriteMismatchedReplacement(t *testing.T) {
+	tests := []struct {
+		input   string
+		replace func(ast.Node) ast.Node
+	}{
+		// an identifier held by value replaced by an expression
+		{"var x = 1;", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.Identifier); ok {
+				return &ast.IntegerLiteral{}
+			}
+			return n
+		}},
+		// an expression replaced by a statement
+		{"1 + 2;", func(n ast.Node) ast.Node {
+			if _, ok := n.(*ast.InfixExpression); ok {
+				return &ast.BlockStatement{}
+			}
+			return n
+		}},
+	}
+
+	for index, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("tests[%d]: expected Rewrite to panic", index)
+				}
+			}()
+			ast.Rewrite(parse(t, test.input), test.replace)
+		}()
+	}
+}
@@ -0,0 +1,447 @@
+// The dap package implements a Debug Adapter Protocol server for Corrosion
+// programs, allowing editors such as VS Code to debug them.
+//
+// The server handles one program per session.  The program runs on its own
+// goroutine under a debugger.Debugger; when the debugger stops, the program
+// goroutine waits for the client to resume it while the server goroutine
+// answers requests for the stack, scopes, variables and expressions.
+//
+// See https://microsoft.github.io/debug-adapter-protocol/specification
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/debugger"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Corrosion programs run on a single thread.
+const threadID = 1
+
+// The Server object represents the state of a debugging session.
+type Server struct {
+	conn     *conn
+	debugger *debugger.Debugger
+	program  *ast.Program
+	path     string
+	done     chan struct{} // closed when the program finishes
+
+	mu         sync.Mutex // guards stopped and quitting
+	stopped    bool       // the program is waiting on resume
+	quitting   bool       // the client asked to end the session
+	resume     chan debugger.Action
+	references []*object.Environment // scopes by variablesReference - 1
+}
+
+// Creates and returns a Server reading requests from in and writing responses
+// and events to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		conn:   newConn(in, out),
+		resume: make(chan debugger.Action),
+	}
+	return s
+}
+
+// Serve handles requests until the client disconnects or the input ends.
+func (s *Server) Serve() error {
+	for {
+		m, err := s.conn.read()
+		if err != nil {
+			s.quit()
+			s.wait()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if m.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(m)
+		s.respond(m, body, err)
+
+		switch {
+		case m.Command == "initialize" && err == nil:
+			s.event("initialized", nil)
+		case m.Command == "disconnect":
+			return nil
+		}
+	}
+}
+
+// Dispatches the request m and returns the body of its response.
+func (s *Server) handle(m *message) (any, error) {
+	switch m.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(m.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(m.Arguments)
+	case "setExceptionBreakpoints":
+		return nil, nil
+	case "configurationDone":
+		return nil, s.start()
+	case "threads":
+		threads := []map[string]any{{"id": threadID, "name": "main"}}
+		return map[string]any{"threads": threads}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(m.Arguments)
+	case "variables":
+		return s.variables(m.Arguments)
+	case "evaluate":
+		return s.evaluate(m.Arguments)
+	case "continue":
+		return map[string]bool{"allThreadsContinued": true},
+			s.continueWith(debugger.Continue)
+	case "next":
+		return nil, s.continueWith(debugger.StepOver)
+	case "stepIn":
+		return nil, s.continueWith(debugger.StepIn)
+	case "stepOut":
+		return nil, s.continueWith(debugger.StepOut)
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.Pause()
+		}
+		return nil, nil
+	case "terminate":
+		s.quit()
+		return nil, nil
+	case "disconnect":
+		s.quit()
+		s.wait()
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", m.Command)
+}
+
+// ----------------------------------------------------------------------------
+// Requests
+// ----------------------------------------------------------------------------
+
+// Parses the program named in the launch arguments.
+func (s *Server) launch(arguments json.RawMessage) error {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return err
+	}
+
+	text, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	l := lexer.New(string(text))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	s.program = program
+	s.path = args.Program
+	s.debugger = debugger.New(s, args.StopOnEntry)
+
+	return nil
+}
+
+// Replaces the breakpoints in the program with those requested.
+func (s *Server) setBreakpoints(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if s.debugger == nil {
+		return nil, errors.New("no program launched")
+	}
+
+	s.debugger.ClearBreakpoints()
+
+	breakpoints := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		s.debugger.SetBreakpoint(b.Line)
+		breakpoints = append(breakpoints,
+			breakpoint{Verified: true, Line: b.Line})
+	}
+
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+// Starts running the program on its own goroutine.  The exited and terminated
+// events are sent when it finishes.
+func (s *Server) start() error {
+	if s.debugger == nil {
+		return errors.New("no program launched")
+	}
+	if s.done != nil {
+		return nil
+	}
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		result, err := s.debugger.Run(s.program, object.NewEnvironment())
+
+		exitCode := 0
+		if err == nil && result != nil && result.Type() == object.ERROR_OBJ {
+			s.event("output", map[string]string{
+				"category": "stderr",
+				"output":   result.Inspect() + "\n",
+			})
+			exitCode = 1
+		}
+
+		s.event("exited", map[string]int{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+
+	return nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	if !s.isStopped() {
+		return nil, errors.New("program is not stopped")
+	}
+
+	src := source{Name: filepath.Base(s.path), Path: s.path}
+
+	frames := []stackFrame{}
+	for i, frame := range s.debugger.Stack() {
+		frames = append(frames, stackFrame{
+			ID:     i,
+			Name:   frame.Name,
+			Source: src,
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+
+	return map[string]any{
+		"stackFrames": frames,
+		"totalFrames": len(frames),
+	}, nil
+}
+
+// Returns a scope for each environment in the chain of the frame, innermost
+// first.
+func (s *Server) scopes(arguments json.RawMessage) (any, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if !s.isStopped() {
+		return nil, errors.New("program is not stopped")
+	}
+
+	stack := s.debugger.Stack()
+	if args.FrameID < 0 || args.FrameID >= len(stack) {
+		return nil, fmt.Errorf("no frame %d", args.FrameID)
+	}
+
+	scopes := []scope{}
+	for env := stack[args.FrameID].Env; env != nil; env = env.Outer() {
+		name := "Enclosing"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+
+		s.references = append(s.references, env)
+		scopes = append(scopes, scope{
+			Name:               name,
+			VariablesReference: len(s.references),
+			Expensive:          env.Outer() == nil,
+		})
+	}
+
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(arguments json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if !s.isStopped() {
+		return nil, errors.New("program is not stopped")
+	}
+
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(s.references) {
+		return nil, fmt.Errorf("no variables reference %d", ref)
+	}
+	env := s.references[ref-1]
+
+	variables := []variable{}
+	for _, name := range env.Names() {
+		obj, _ := env.GetLocal(name)
+		variables = append(variables, variable{
+			Name:  name,
+			Value: obj.Inspect(),
+			Type:  string(obj.Type()),
+		})
+	}
+
+	return map[string]any{"variables": variables}, nil
+}
+
+// Evaluates a watch, hover or console expression in the requested frame.
+func (s *Server) evaluate(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if !s.isStopped() {
+		return nil, errors.New("program is not stopped")
+	}
+
+	input := args.Expression
+	if !strings.HasSuffix(strings.TrimSpace(input), ";") {
+		input += ";"
+	}
+
+	result, err := s.debugger.Evaluate(input, args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type() == object.ERROR_OBJ {
+		return nil, errors.New(result.Inspect())
+	}
+
+	return map[string]any{
+		"result":             result.Inspect(),
+		"type":               string(result.Type()),
+		"variablesReference": 0,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+// Debugger handler
+// ----------------------------------------------------------------------------
+
+// Stopped is called on the program goroutine when the debugger stops.  It
+// reports the stop to the client and waits for a request to resume.
+func (s *Server) Stopped(d *debugger.Debugger, reason string) debugger.Action {
+	s.mu.Lock()
+	if s.quitting {
+		s.mu.Unlock()
+		return debugger.Quit
+	}
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	return <-s.resume
+}
+
+// Resumes the stopped program with action.
+func (s *Server) continueWith(action debugger.Action) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.mu.Unlock()
+		return errors.New("program is not stopped")
+	}
+	s.stopped = false
+	s.mu.Unlock()
+
+	s.references = nil
+	s.resume <- action
+	return nil
+}
+
+// Ends the program: immediately if it is stopped, otherwise at its next
+// statement.
+func (s *Server) quit() {
+	s.mu.Lock()
+	s.quitting = true
+	stopped := s.stopped
+	s.stopped = false
+	s.mu.Unlock()
+
+	s.references = nil
+	if stopped {
+		s.resume <- debugger.Quit
+	} else if s.debugger != nil {
+		s.debugger.Pause()
+	}
+}
+
+// Waits for the program to finish, if it was started.
+func (s *Server) wait() {
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+func (s *Server) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// ----------------------------------------------------------------------------
+// Helpers
+// ----------------------------------------------------------------------------
+
+func (s *Server) respond(request *message, body any, err error) {
+	success := err == nil
+	m := &message{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Command:    request.Command,
+		Success:    &success,
+		Body:       body,
+	}
+	if err != nil {
+		m.Message = err.Error()
+	}
+	s.conn.write(m)
+}
+
+func (s *Server) event(name string, body any) {
+	s.conn.write(&message{Type: "event", Event: name, Body: body})
+}
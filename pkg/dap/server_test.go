@@ -0,0 +1,317 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const program = `var total = 0;
+func add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+total = add(1, 2);
+total = add(total, 10);`
+
+// client drives a Server over pipes.
+type client struct {
+	t        *testing.T
+	conn     *conn
+	messages chan *message
+	pending  []*message // messages read while expecting another
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	server := NewServer(serverIn, serverOut)
+	go func() {
+		server.Serve()
+		serverOut.Close()
+	}()
+
+	c := &client{
+		t:        t,
+		conn:     newConn(clientIn, clientOut),
+		messages: make(chan *message, 100),
+	}
+
+	go func() {
+		for {
+			m, err := c.conn.read()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- m
+		}
+	}()
+
+	t.Cleanup(func() { clientOut.Close() })
+
+	return c
+}
+
+// Sends a request and returns the body of its response, which must succeed.
+func (c *client) request(command string, arguments any) map[string]any {
+	c.t.Helper()
+
+	m := c.send(command, arguments)
+	if !*m.Success {
+		c.t.Fatalf("%s failed: %s", command, m.Message)
+	}
+
+	body, _ := m.Body.(map[string]any)
+	return body
+}
+
+// Sends a request and returns its response.
+func (c *client) send(command string, arguments any) *message {
+	c.t.Helper()
+
+	c.post(command, arguments)
+	return c.expect("response", command)
+}
+
+// Sends a request without waiting for the response.
+func (c *client) post(command string, arguments any) {
+	c.t.Helper()
+
+	args, err := json.Marshal(arguments)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if err := c.conn.write(&message{
+		Type:      "request",
+		Command:   command,
+		Arguments: args,
+	}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// Returns the first message of type kind named name, reading more messages
+// as needed.  Messages passed over are kept for later calls, since events
+// from the program may arrive before the response to a request.
+func (c *client) expect(kind, name string) *message {
+	c.t.Helper()
+
+	matches := func(m *message) bool {
+		return m.Type == kind && (m.Command == name || m.Event == name)
+	}
+
+	for i, m := range c.pending {
+		if matches(m) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return m
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed waiting for %s %s", kind, name)
+			}
+			if matches(m) {
+				return m
+			}
+			c.pending = append(c.pending, m)
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s %s", kind, name)
+		}
+	}
+}
+
+// Waits for a stopped event and returns its reason and the top frame line.
+func (c *client) stopped() (string, int) {
+	c.t.Helper()
+
+	event := c.expect("event", "stopped")
+	reason := event.Body.(map[string]any)["reason"].(string)
+
+	body := c.request("stackTrace", map[string]int{"threadId": threadID})
+	frames := body["stackFrames"].([]any)
+	line := frames[0].(map[string]any)["line"].(float64)
+
+	return reason, int(line)
+}
+
+func launch(t *testing.T, c *client, stopOnEntry bool, breakpoints ...int) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "program.cr")
+	if err := os.WriteFile(path, []byte(program), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c.request("initialize", map[string]string{"adapterID": "corrosion"})
+	c.expect("event", "initialized")
+	c.request("launch", map[string]any{
+		"program":     path,
+		"stopOnEntry": stopOnEntry,
+	})
+
+	lines := []map[string]int{}
+	for _, line := range breakpoints {
+		lines = append(lines, map[string]int{"line": line})
+	}
+	c.request("setBreakpoints", map[string]any{
+		"source":      map[string]string{"path": path},
+		"breakpoints": lines,
+	})
+
+	c.request("configurationDone", nil)
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c := newClient(t)
+	launch(t, c, false, 3)
+
+	if reason, line := c.stopped(); reason != "breakpoint" || line != 3 {
+		t.Fatalf("expected breakpoint at 3. got=%s at %d", reason, line)
+	}
+
+	body := c.request("stackTrace", map[string]int{"threadId": threadID})
+	frames := body["stackFrames"].([]any)
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames. got=%d", len(frames))
+	}
+	if name := frames[0].(map[string]any)["name"]; name != "add" {
+		t.Errorf("expected frame add. got=%v", name)
+	}
+
+	body = c.request("scopes", map[string]int{"frameId": 0})
+	scopes := body["scopes"].([]any)
+	if len(scopes) != 2 {
+		t.Fatalf("expected 2 scopes. got=%d", len(scopes))
+	}
+	locals := scopes[0].(map[string]any)
+	if locals["name"] != "Locals" {
+		t.Errorf("expected Locals. got=%v", locals["name"])
+	}
+
+	body = c.request("variables", map[string]any{
+		"variablesReference": locals["variablesReference"],
+	})
+	variables := body["variables"].([]any)
+	values := map[string]any{}
+	for _, v := range variables {
+		v := v.(map[string]any)
+		values[v["name"].(string)] = v["value"]
+	}
+	if values["a"] != "1" || values["b"] != "2" || len(values) != 2 {
+		t.Errorf("unexpected locals %v", values)
+	}
+
+	body = c.request("evaluate", map[string]any{
+		"expression": "a + b * 10",
+		"frameId":    0,
+		"context":    "watch",
+	})
+	if body["result"] != "21" {
+		t.Errorf("expected evaluate result 21. got=%v", body["result"])
+	}
+
+	if m := c.send("evaluate", map[string]any{
+		"expression": "missing",
+		"frameId":    0,
+	}); *m.Success {
+		t.Errorf("expected evaluating an undefined identifier to fail")
+	}
+
+	c.request("next", map[string]int{"threadId": threadID})
+	if reason, line := c.stopped(); reason != "step" || line != 4 {
+		t.Fatalf("expected step to 4. got=%s at %d", reason, line)
+	}
+
+	c.request("stepOut", map[string]int{"threadId": threadID})
+	if reason, line := c.stopped(); reason != "step" || line != 7 {
+		t.Fatalf("expected step out to 7. got=%s at %d", reason, line)
+	}
+
+	c.request("setBreakpoints", map[string]any{
+		"breakpoints": []map[string]int{},
+	})
+	c.request("continue", map[string]int{"threadId": threadID})
+
+	exited := c.expect("event", "exited")
+	if code := exited.Body.(map[string]any)["exitCode"]; code != 0.0 {
+		t.Errorf("expected exit code 0. got=%v", code)
+	}
+	c.expect("event", "terminated")
+	c.request("disconnect", nil)
+}
+
+func TestStopOnEntryAndDisconnect(t *testing.T) {
+	c := newClient(t)
+	launch(t, c, true)
+
+	if reason, line := c.stopped(); reason != "entry" || line != 1 {
+		t.Fatalf("expected entry at 1. got=%s at %d", reason, line)
+	}
+
+	if m := c.send("stackTrace", nil); !*m.Success {
+		t.Errorf("stackTrace failed: %s", m.Message)
+	}
+
+	// the program is ended before the response
+	c.post("disconnect", nil)
+	c.expect("event", "terminated")
+	c.expect("response", "disconnect")
+}
+
+// The references handed out while the program was stopped are dropped when it
+// is terminated, and variables are only read while it is stopped.
+func TestVariablesAfterTerminate(t *testing.T) {
+	c := newClient(t)
+	launch(t, c, true)
+
+	if reason, line := c.stopped(); reason != "entry" || line != 1 {
+		t.Fatalf("expected entry at 1. got=%s at %d", reason, line)
+	}
+
+	body := c.request("scopes", map[string]int{"frameId": 0})
+	globals := body["scopes"].([]any)[0].(map[string]any)
+	reference := map[string]any{
+		"variablesReference": globals["variablesReference"],
+	}
+
+	c.request("terminate", nil)
+	c.expect("event", "terminated")
+
+	if m := c.send("variables", reference); *m.Success {
+		t.Errorf("expected variables to fail after terminate")
+	}
+
+	c.request("disconnect", nil)
+}
+
+func TestRequestErrors(t *testing.T) {
+	c := newClient(t)
+
+	tests := []struct {
+		command   string
+		arguments any
+	}{
+		{"launch", map[string]string{"program": "missing.cr"}},
+		{"configurationDone", nil},
+		{"stackTrace", nil},
+		{"continue", nil},
+		{"variables", map[string]int{"variablesReference": 7}},
+		{"bogus", nil},
+	}
+
+	for index, test := range tests {
+		if m := c.send(test.command, test.arguments); *m.Success {
+			t.Errorf("tests[%d]: expected %s to fail", index, test.command)
+		}
+	}
+}
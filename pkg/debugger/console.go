@@ -0,0 +1,183 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consolePrompt = "(debug) "
+
+const consoleHelp = `break N, b N     set a breakpoint on line N
+clear N          remove the breakpoint on line N
+breakpoints      list the breakpoints
+continue, c      run until the next breakpoint
+step, s          step to the next line, entering calls
+next, n          step to the next line in the current function
+out, o           run until the current function returns
+print NAME, p    print the value of NAME in the current scope
+vars, v          print the variables in each enclosing scope
+backtrace, bt    print the call stack
+list, l          print the source around the current line
+quit, q          stop debugging
+help, h          print this message`
+
+// Console is a Handler that reads debugger commands from a reader and writes
+// the results to a writer, for use from a terminal.
+type Console struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+	source  []string // lines of the program being debugged
+}
+
+// Creates and returns a Console reading commands from in and writing to out.
+// source is the text of the program, used to show the current line.
+func NewConsole(in io.Reader, out io.Writer, source string) *Console {
+	return &Console{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+		source:  strings.Split(source, "\n"),
+	}
+}
+
+// Stopped shows where the program stopped and reads commands until one of
+// them resumes execution.
+func (c *Console) Stopped(d *Debugger, reason string) Action {
+	frame := d.Stack()[0]
+	fmt.Fprintf(c.out, "stopped (%s) in %s at line %d\n",
+		reason, frame.Name, frame.Line)
+	c.printLine(frame.Line, frame.Line)
+
+	for {
+		fmt.Fprint(c.out, consolePrompt)
+		if !c.scanner.Scan() {
+			fmt.Fprintln(c.out)
+			return Quit
+		}
+
+		fields := strings.Fields(c.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if action, resume := c.command(d, fields[0], fields[1:]); resume {
+			return action
+		}
+	}
+}
+
+// Executes the command name with args.  Returns the action to take and true
+// if the command resumes execution.
+func (c *Console) command(
+	d *Debugger, name string, args []string,
+) (Action, bool) {
+	switch name {
+	case "continue", "c":
+		return Continue, true
+	case "step", "s":
+		return StepIn, true
+	case "next", "n":
+		return StepOver, true
+	case "out", "o":
+		return StepOut, true
+	case "quit", "q":
+		return Quit, true
+	case "break", "b":
+		if line, ok := c.lineArgument(name, args); ok {
+			d.SetBreakpoint(line)
+			fmt.Fprintf(c.out, "breakpoint set on line %d\n", line)
+		}
+	case "clear":
+		if line, ok := c.lineArgument(name, args); ok {
+			d.ClearBreakpoint(line)
+			fmt.Fprintf(c.out, "breakpoint cleared on line %d\n", line)
+		}
+	case "breakpoints":
+		for _, line := range d.Breakpoints() {
+			c.printLine(line, line)
+		}
+	case "print", "p":
+		c.print(d, args)
+	case "vars", "v":
+		c.vars(d)
+	case "backtrace", "bt":
+		for i, frame := range d.Stack() {
+			fmt.Fprintf(c.out, "#%d %s at line %d\n", i, frame.Name, frame.Line)
+		}
+	case "list", "l":
+		line := d.Stack()[0].Line
+		c.printLine(line-3, line+3)
+	case "help", "h":
+		fmt.Fprintln(c.out, consoleHelp)
+	default:
+		fmt.Fprintf(c.out, "unknown command %q (try help)\n", name)
+	}
+
+	return Continue, false
+}
+
+// Prints the value of each named variable, looked up from the innermost
+// scope outwards.
+func (c *Console) print(d *Debugger, names []string) {
+	if len(names) == 0 {
+		fmt.Fprintln(c.out, "usage: print NAME")
+		return
+	}
+
+	env := d.Stack()[0].Env
+	for _, name := range names {
+		obj, ok := env.Get(name)
+		if !ok {
+			fmt.Fprintf(c.out, "undefined identifier %q\n", name)
+			continue
+		}
+		fmt.Fprintf(c.out, "%s = %s\n", name, obj.Inspect())
+	}
+}
+
+// Prints the bindings in each scope of the current environment chain, the
+// innermost scope first and the global scope last.
+func (c *Console) vars(d *Debugger) {
+	depth := 0
+	for env := d.Stack()[0].Env; env != nil; env = env.Outer() {
+		if env.Outer() == nil {
+			fmt.Fprintln(c.out, "global:")
+		} else {
+			fmt.Fprintf(c.out, "scope %d:\n", depth)
+		}
+		for _, name := range env.Names() {
+			obj, _ := env.GetLocal(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, obj.Inspect())
+		}
+		depth++
+	}
+}
+
+// Prints the source lines from first to last, numbered, skipping lines that
+// are outside of the program.
+func (c *Console) printLine(first, last int) {
+	first = max(first, 1)
+	last = min(last, len(c.source))
+
+	for line := first; line <= last; line++ {
+		fmt.Fprintf(c.out, "%4d  %s\n", line, c.source[line-1])
+	}
+}
+
+// Parses the line number argument of command name.
+func (c *Console) lineArgument(name string, args []string) (int, bool) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.out, "usage: %s N\n", name)
+		return 0, false
+	}
+
+	line, err := strconv.Atoi(args[0])
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "invalid line %q\n", args[0])
+		return 0, false
+	}
+
+	return line, true
+}
@@ -0,0 +1,271 @@
+// The debugger package implements a statement level debugger for Corrosion
+// programs with line breakpoints, stepping and call stack inspection.
+//
+// A Debugger is installed as the evaluator hook for the program environment.
+// Before each statement it decides whether to stop and, when it does, asks
+// its Handler how execution should continue.  The handler runs on the
+// evaluating goroutine, so the program is paused until it returns.
+package debugger
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Action tells the debugger how to continue after stopping.
+type Action int
+
+const (
+	Continue Action = iota // run until the next breakpoint
+	StepIn                 // stop at the next line, entering calls
+	StepOver               // stop at the next line in the current function
+	StepOut                // stop after the current function returns
+	Quit                   // abandon the program
+)
+
+// Reasons for stopping, passed to Handler.Stopped.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Name of the frame for statements outside of any function.
+const mainFrame = "<main>"
+
+// Returned by Run when the handler chose to Quit.
+var ErrQuit = errors.New("debugging session ended")
+
+// Frame is an entry in the call stack.
+type Frame struct {
+	Name string              // name of the called function
+	Line int                 // line of the statement being evaluated
+	Env  *object.Environment // innermost environment of the statement
+	call int                 // numbers the calls, as tail calls reuse a depth
+}
+
+// Handler is notified when the debugger stops and returns how to continue.
+type Handler interface {
+	Stopped(d *Debugger, reason string) Action
+}
+
+// The Debugger object represents the state of a debugging session.
+//
+// Breakpoints may be changed and Pause called from any goroutine.  The other
+// methods must be called from the Handler while the program is stopped.
+type Debugger struct {
+	handler     Handler
+	mu          sync.Mutex   // guards breakpoints
+	breakpoints map[int]bool // lines with breakpoints
+	pause       atomic.Bool  // stop at the next statement
+	stack       []Frame
+	entry       bool   // stop before the first statement
+	action      Action // the action chosen at the last stop
+	depth       int    // the stack depth at the last stop
+	call        int    // the call of the frame at the last stop
+	calls       int    // the number of calls entered
+	evaluating  bool   // evaluating on behalf of the handler
+}
+
+// Creates and returns a Debugger that reports stops to handler.  When
+// stopOnEntry is true, the debugger stops before the first statement.
+func New(handler Handler, stopOnEntry bool) *Debugger {
+	return &Debugger{
+		handler:     handler,
+		breakpoints: make(map[int]bool),
+		entry:       stopOnEntry,
+	}
+}
+
+// Run evaluates program in env under the debugger and returns the result.
+// ErrQuit is returned if the handler ended the session.
+func (d *Debugger) Run(
+	program *ast.Program, env *object.Environment,
+) (result object.Object, err error) {
+	d.stack = []Frame{{Name: mainFrame, Env: env}}
+
+	env.SetHook(d)
+	defer env.SetHook(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if r != ErrQuit {
+				panic(r)
+			}
+			result, err = nil, ErrQuit
+		}
+		d.stack = nil
+	}()
+
+	return evaluator.Eval(program, env), nil
+}
+
+// ----------------------------------------------------------------------------
+// Breakpoints and state
+// ----------------------------------------------------------------------------
+
+// Sets a breakpoint on line.  The debugger stops at the first statement that
+// starts on the line.
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+// Removes the breakpoint on line, if any.
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// Removes all breakpoints.
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.breakpoints)
+}
+
+// Returns the lines with breakpoints in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Requests a stop at the next statement.  Unlike the other methods, Pause
+// may be called while the program is running.
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Reports whether line has a breakpoint.
+func (d *Debugger) breakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[line]
+}
+
+// Evaluates input in the environment of the frame at index in Stack and
+// returns the result.  The debugger does not stop while evaluating.
+func (d *Debugger) Evaluate(input string, index int) (object.Object, error) {
+	if index < 0 || index >= len(d.stack) {
+		return nil, errors.New("no such frame")
+	}
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	return evaluator.Eval(program, d.Stack()[index].Env), nil
+}
+
+// Returns the call stack, innermost frame first.
+func (d *Debugger) Stack() []Frame {
+	frames := make([]Frame, len(d.stack))
+	for i, frame := range d.stack {
+		frames[len(d.stack)-1-i] = frame
+	}
+	return frames
+}
+
+// ----------------------------------------------------------------------------
+// Evaluator hook
+// ----------------------------------------------------------------------------
+
+// Statement is called by the evaluator before each statement.  It records the
+// position in the current frame and stops if a breakpoint or step requires it.
+func (d *Debugger) Statement(node ast.Statement, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	line := ast.Line(node)
+	depth := len(d.stack)
+
+	frame := &d.stack[depth-1]
+	changed := frame.Line != line
+	frame.Line = line
+	frame.Env = env
+
+	reason := ""
+	switch {
+	case d.entry:
+		d.entry = false
+		reason = ReasonEntry
+	case d.pause.Swap(false):
+		reason = ReasonPause
+	case changed && d.breakpoint(line):
+		reason = ReasonBreakpoint
+	case d.action == StepIn && (changed || depth != d.depth):
+		reason = ReasonStep
+	case d.action == StepOver && depth < d.depth:
+		reason = ReasonStep
+	case d.action == StepOver && depth == d.depth && changed &&
+		frame.call == d.call:
+		reason = ReasonStep
+	case d.action == StepOut && depth < d.depth:
+		reason = ReasonStep
+	}
+
+	if reason == "" {
+		return
+	}
+
+	action := d.handler.Stopped(d, reason)
+	if action == Quit {
+		panic(ErrQuit)
+	}
+	d.action = action
+	d.depth = depth
+	d.call = frame.call
+}
+
+// EnterCall is called by the evaluator when a function call begins.
+func (d *Debugger) EnterCall(
+	node *ast.FunctionCallExpression,
+	fn *object.Function,
+	env *object.Environment,
+) {
+	if d.evaluating {
+		return
+	}
+	d.calls++
+	d.stack = append(d.stack, Frame{
+		Name: node.Function.String(),
+		Env:  env,
+		call: d.calls,
+	})
+}
+
+// ExitCall is called by the evaluator when a function call returns.
+func (d *Debugger) ExitCall(
+	node *ast.FunctionCallExpression, result object.Object,
+) {
+	if d.evaluating {
+		return
+	}
+	d.stack = d.stack[:len(d.stack)-1]
+}
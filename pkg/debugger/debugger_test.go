@@ -0,0 +1,235 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+const program = `var total = 0;
+func add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+func twice(n) {
+  var once = add(n, n);
+  return add(once, once);
+}
+total = twice(3);
+total = total + 1;`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	return program
+}
+
+// Handler that records each stop as "reason name:line" and answers with the
+// next action from a script, quitting when the script runs out.
+type recorder struct {
+	actions []Action
+	stops   []string
+}
+
+func (r *recorder) Stopped(d *Debugger, reason string) Action {
+	frame := d.Stack()[0]
+	r.stops = append(r.stops,
+		fmt.Sprintf("%s %s:%d", reason, frame.Name, frame.Line))
+
+	if len(r.actions) == 0 {
+		return Quit
+	}
+	action := r.actions[0]
+	r.actions = r.actions[1:]
+	return action
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		breakpoints []int
+		actions     []Action
+		expected    []string
+	}{
+		{
+			nil,
+			[]Action{StepOver, StepOver, StepOver, StepOver, StepOver},
+			[]string{
+				"entry <main>:1",
+				"step <main>:2",
+				"step <main>:6",
+				"step <main>:10",
+				"step <main>:11",
+			},
+		},
+		{
+			nil,
+			[]Action{StepOver, StepOver, StepOver, StepIn, StepIn, StepIn,
+				StepOut, StepOut, Continue},
+			[]string{
+				"entry <main>:1",
+				"step <main>:2",
+				"step <main>:6",
+				"step <main>:10",
+				"step twice:7",
+				"step add:3",
+				"step add:4",
+				"step twice:8",
+				"step <main>:11",
+			},
+		},
+		{
+			[]int{4},
+			[]Action{Continue, Continue, StepOut, Continue},
+			[]string{
+				"entry <main>:1",
+				"breakpoint add:4",
+				"breakpoint add:4",
+				"step <main>:11",
+			},
+		},
+		{
+			[]int{7, 11},
+			[]Action{Continue, StepOver, StepOver, Continue},
+			[]string{
+				"entry <main>:1",
+				"breakpoint twice:7",
+				"step twice:8",
+				"breakpoint <main>:11",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		r := &recorder{actions: test.actions}
+		d := New(r, true)
+		for _, line := range test.breakpoints {
+			d.SetBreakpoint(line)
+		}
+
+		env := object.NewEnvironment()
+		result, err := d.Run(parse(t, program), env)
+		if err != nil {
+			t.Errorf("tests[%d]: unexpected error %v", index, err)
+		}
+		if result == nil || result.Type() == object.ERROR_OBJ {
+			t.Errorf("tests[%d]: unexpected result %v", index, result)
+		}
+
+		if strings.Join(r.stops, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("tests[%d]: expected stops=%q got=%q",
+				index, test.expected, r.stops)
+		}
+
+		if total, _ := env.Get("total"); total.Inspect() != "13" {
+			t.Errorf("tests[%d]: expected total=13 got=%s",
+				index, total.Inspect())
+		}
+	}
+}
+
+func TestStackAndQuit(t *testing.T) {
+	var stack []Frame
+
+	handler := handlerFunc(func(d *Debugger, reason string) Action {
+		stack = d.Stack()
+		return Quit
+	})
+
+	d := New(handler, false)
+	d.SetBreakpoint(3)
+
+	env := object.NewEnvironment()
+	result, err := d.Run(parse(t, program), env)
+	if err != ErrQuit || result != nil {
+		t.Fatalf("expected ErrQuit. got=%v, %v", result, err)
+	}
+
+	expected := []string{"add:3", "twice:7", "<main>:10"}
+	if len(stack) != len(expected) {
+		t.Fatalf("expected %d frames got=%d", len(expected), len(stack))
+	}
+	for i, frame := range stack {
+		if got := fmt.Sprintf("%s:%d", frame.Name, frame.Line); got != expected[i] {
+			t.Errorf("frame[%d]: expected=%s got=%s", i, expected[i], got)
+		}
+	}
+
+	if a, _ := stack[0].Env.Get("a"); a.Inspect() != "3" {
+		t.Errorf("expected a=3 in the innermost frame. got=%s", a.Inspect())
+	}
+
+	if env.Hook() != nil {
+		t.Errorf("hook not removed from the environment")
+	}
+}
+
+type handlerFunc func(d *Debugger, reason string) Action
+
+func (f handlerFunc) Stopped(d *Debugger, reason string) Action {
+	return f(d, reason)
+}
+
+func TestConsole(t *testing.T) {
+	commands := strings.Join([]string{
+		"b 4",
+		"b x",
+		"c",
+		"p sum a missing",
+		"bt",
+		"vars",
+		"clear 4",
+		"breakpoints",
+		"bogus",
+		"c",
+	}, "\n")
+
+	var out bytes.Buffer
+	console := NewConsole(strings.NewReader(commands), &out, program)
+	d := New(console, true)
+
+	if _, err := d.Run(parse(t, program), object.NewEnvironment()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := `stopped (entry) in <main> at line 1
+   1  var total = 0;
+(debug) breakpoint set on line 4
+(debug) invalid line "x"
+(debug) stopped (breakpoint) in add at line 4
+   4    return sum;
+(debug) sum = 6
+a = 3
+undefined identifier "missing"
+(debug) #0 add at line 4
+#1 twice at line 7
+#2 <main> at line 10
+(debug) scope 0:
+  a = 3
+  b = 3
+  sum = 6
+global:
+  add = func(a, b) {var sum = (a + b);return sum; }
+  total = 0
+  twice = func(n) {var once = add(n, n);return add(once, once); }
+(debug) breakpoint cleared on line 4
+(debug) (debug) unknown command "bogus" (try help)
+(debug) `
+
+	if out.String() != expected {
+		t.Errorf("unexpected output.\nexpected=%q\ngot=%q",
+			expected, out.String())
+	}
+}
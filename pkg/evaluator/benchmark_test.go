@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+const fib = `func fib(n) {
+  if (n < 2) { return n; }
+  return fib(n - 1) + fib(n - 2);
+}
+fib(25);`
+
+// Parses input, failing tb if it does not parse.
+func parse(tb testing.TB, input string) *ast.Program {
+	tb.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		tb.Fatalf("parse errors: %v", errs)
+	}
+
+	return program
+}
+
+// Compares identifiers resolved to slots with identifiers looked up by name,
+// as every identifier was before resolution.
+func BenchmarkFib25(b *testing.B) {
+	b.Run("slots", func(b *testing.B) {
+		program := parse(b, fib)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result := Eval(program, object.NewEnvironment())
+			if result.Inspect() != "75025" {
+				b.Fatalf("expected 75025 got=%s", result.Inspect())
+			}
+		}
+	})
+
+	b.Run("names", func(b *testing.B) {
+		program := parse(b, fib)
+		ast.Inspect(program, func(n ast.Node) bool {
+			if identifier, ok := n.(*ast.Identifier); ok {
+				identifier.Depth = -1
+			}
+			return true
+		})
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			// evaluated without Eval, which would resolve the program
+			result := evalStatements(program.Statements, object.NewEnvironment())
+			if result.Inspect() != "75025" {
+				b.Fatalf("expected 75025 got=%s", result.Inspect())
+			}
+		}
+	})
+}
+
+// Declares and reads 2000 globals, evaluating the statements one at a time as
+// the REPL does, so that every identifier is looked up by name.
+func BenchmarkDeclarations(b *testing.B) {
+	var input strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&input, "var v%d = %d; v%d;\n", i, i, i)
+	}
+	program := parse(b, input.String())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		env := object.NewEnvironment()
+		for _, statement := range program.Statements {
+			if result := Eval(statement, env); checkEvalError(result) {
+				b.Fatal(result.Inspect())
+			}
+		}
+	}
+}
+
+// Parses and resolves input, and returns it with an environment declaring x.
+func prepare(tb testing.TB, input string) (*ast.Program, *object.Environment) {
+	tb.Helper()
+
+	program := parse(tb, input)
+	Resolve(program)
+
+	env := object.NewEnvironment()
+	env.Set("x", newInteger(7))
+
+	return program, env
+}
+
+// Guards the evaluation of literals, small integers and booleans against
+// allocating.
+func TestAllocations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1; 1023; -128; true;", 0},
+		{"9223372036854775808;", 0},
+		{"(x + 1) * 2 - x / 3 % 5;", 0},
+		{"-x; ~x; -(-1);", 0},
+		{"!(x < 10) || x == 7 && !false;", 0},
+		{"x++; x--; x += 1; x -= 1;", 0},
+		{"x > 5 ? 1 : 2;", 0},
+		{"x * 1000;", 1},
+		{"x * 1000000 + 123456789;", 2},
+	}
+
+	for index, test := range tests {
+		program, env := prepare(t, test.input)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			evalStatements(program.Statements, env)
+		})
+		if allocs != test.expected {
+			t.Errorf("tests[%d]: expected %v allocations got=%v",
+				index, test.expected, allocs)
+		}
+	}
+}
+
+// Evaluates input once per iteration.
+func benchmarkEval(b *testing.B, input string) {
+	program, env := prepare(b, input)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := evalStatements(program.Statements, env)
+		if checkEvalError(result) {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func BenchmarkIntegerLiterals(b *testing.B) {
+	benchmarkEval(b, "1; 1000; 123456789; 9223372036854775808;")
+}
+
+func BenchmarkSmallIntegerArithmetic(b *testing.B) {
+	benchmarkEval(b, "(x + 1) * 2 - x / 3 % 5;")
+}
+
+func BenchmarkLargeIntegerArithmetic(b *testing.B) {
+	benchmarkEval(b, "x * 1000000 + 123456789;")
+}
+
+func BenchmarkBooleanLogic(b *testing.B) {
+	benchmarkEval(b, "!(x < 10) || x == 7 && !false;")
+}
+
+func BenchmarkIncrement(b *testing.B) {
+	benchmarkEval(b, "x++; x--;")
+}
+
+func BenchmarkMatch(b *testing.B) {
+	benchmarkEval(b,
+		"match (x) { 1 => true, n if n > 5 => false, _ => true };")
+}
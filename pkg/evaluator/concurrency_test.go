@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Run with -race to check that evaluations in separate environments share no
+// mutable state, even when they share a program.
+
+const concurrentProgram = `
+const limit = 20;
+var total = 0;
+func fib(n) { if (n < 2) { return n; } return fib(n - 1) + fib(n - 2); }
+func count(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }
+func classify(n) {
+  return match (n % 3) { 0 => true, r if r == 1 => false, _ => !true };
+}
+total += fib(limit - 5) + count(500, 0);
+var big = 9223372036854775807 + total;
+total = if (classify(total) == (1 < 2)) { total } else { -total };
+big - total + big % 1000;
+`
+
+func TestConcurrentEvaluation(t *testing.T) {
+	const goroutines = 16
+
+	programs := []string{
+		concurrentProgram,
+		"var x = 0; x++; x += 2 ** 10; !(x > 5) || x == 1027;",
+		"func f(a, b) { return a * b - ~a; } f(3, 4) + f(-128, 1023);",
+	}
+
+	for index, input := range programs {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		// the result of evaluating alone
+		expected := Eval(program, object.NewEnvironment()).Inspect()
+
+		var wg sync.WaitGroup
+		results := make([]string, goroutines)
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				results[g] = Eval(program, object.NewEnvironment()).Inspect()
+			}(g)
+		}
+		wg.Wait()
+
+		for g, result := range results {
+			if result != expected {
+				t.Errorf("tests[%d]: goroutine %d: expected %q got=%q",
+					index, g, expected, result)
+			}
+		}
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 2*goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			// each parses its own program and runs it under its own hook
+			input := fmt.Sprintf(`func sum(n, acc) {
+			  if (n == 0) { return acc; }
+			  return sum(n - 1, acc + n);
+			}
+			var t = true;
+			if (!t == false) { sum(%d, 0) } else { -1 }`, g*100)
+
+			l := lexer.New(input)
+			p := parser.New(l)
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				errs <- fmt.Sprintf("goroutine %d: parser errors: %v",
+					g, p.Errors())
+				return
+			}
+
+			h := &depthHook{}
+			env := object.NewEnvironment()
+			env.SetHook(h)
+
+			expected := fmt.Sprint(g * 100 * (g*100 + 1) / 2)
+			if result := Eval(program, env).Inspect(); result != expected {
+				errs <- fmt.Sprintf("goroutine %d: expected %s got=%s",
+					g, expected, result)
+			}
+			if h.calls != g*100+1 || h.depth != 0 {
+				errs <- fmt.Sprintf("goroutine %d: expected %d calls "+
+					"got=%d", g, g*100+1, h.calls)
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,20 @@
+// The evaluator package evaluates Corrosion programs.
+//
+// All of the state of an evaluation is held by the environment it runs in
+// and the objects it creates.  The package's own variables, such as NULL,
+// TRUE, FALSE and the shared small integers, are never modified once the
+// package is initialized, and objects are never modified once created, so
+// any number of evaluations may run concurrently provided each has its own
+// environment.  They may share a parsed program: Eval resolves a program only
+// the first time it is evaluated, and never modifies it afterwards.  An
+// environment, and the functions declared in it, must only be used by one
+// evaluation at a time.
 package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 
 	"github.com/freddiehaddad/corrosion/pkg/ast"
 	"github.com/freddiehaddad/corrosion/pkg/object"
@@ -17,19 +30,38 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Integers from smallIntegerMin to smallIntegerMax are created once and
+// shared, since objects are never modified once created.
+const (
+	smallIntegerMin = -128
+	smallIntegerMax = 1023
+)
+
+var smallIntegers [smallIntegerMax - smallIntegerMin + 1]object.Integer
+
+// The largest number of bits an integer computed by ** or << may have.
+// Larger results would take too much time and memory to compute.
+const maxIntegerBits = 1 << 20
+
 // ----------------------------------------------------------------------------
 // Type comparisons help functions
 // ----------------------------------------------------------------------------
 
 type comparisonFunction func(string, object.Object, object.Object) object.Object
 
+// Populated by init and only read afterwards.
 var comparisonFunctions map[object.ObjectType]comparisonFunction
 
 func init() {
+	for i := range smallIntegers {
+		smallIntegers[i].Value = int64(i + smallIntegerMin)
+	}
+
 	comparisonFunctions = make(map[object.ObjectType]comparisonFunction)
 
 	comparisonFunctions[object.BOOLEAN_OBJ] = compareBooleans
 	comparisonFunctions[object.INTEGER_OBJ] = compareIntegers
+	comparisonFunctions[object.BIGINT_OBJ] = compareBigIntegers
 }
 
 // ----------------------------------------------------------------------------
@@ -38,9 +70,13 @@ func init() {
 
 // Evaluates the node and returns an object representing the expression value.
 // Returns NULL object for non-value producing statements.
+//
+// A program may be evaluated by several goroutines at once, but env, and the
+// functions declared in it, must only be used by one of them at a time.
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
+		resolveOnce(node)
 		return evalStatements(node.Statements, env)
 	case *ast.VariableDeclarationStatement:
 		return evalDeclarationStatement(node, env)
@@ -50,20 +86,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.PrefixExpression:
 		return evalPrefixExpression(node, env)
+	case *ast.PostfixExpression:
+		return evalPostfixExpression(node, env)
 	case *ast.InfixExpression:
 		return evalInfixExpression(node, env)
+	case *ast.ConditionalExpression:
+		return evalConditionalExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.AssignmentExpression:
 		return evalAssignmentExpression(node, env)
 	case *ast.Boolean:
 		return evalBooleanExpression(node, env)
 	case *ast.IntegerLiteral:
 		return evalIntegerLiteral(node, env)
+	case *ast.BigIntegerLiteral:
+		return evalBigIntegerLiteral(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.IfStatement:
 		return evalIfStatement(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, object.NewScopedEnvironment(env))
 	case *ast.FunctionCallExpression:
 		return evalFunctionCallExpression(node, env)
 	case *ast.ReturnStatement:
@@ -91,18 +135,39 @@ func evalBooleanObject(value bool) *object.Boolean {
 	return FALSE
 }
 
+// Returns an object for value, shared with other uses of the value if it is a
+// small integer.
+func newInteger(value int64) *object.Integer {
+	if value >= smallIntegerMin && value <= smallIntegerMax {
+		return &smallIntegers[value-smallIntegerMin]
+	}
+	return &object.Integer{Value: value}
+}
+
+// Integer literals evaluate to the object created for them when the program
+// was resolved, if it was.
 func evalIntegerLiteral(
 	i *ast.IntegerLiteral, env *object.Environment,
 ) object.Object {
-	return &object.Integer{
-		Value: i.Value,
+	if obj, ok := i.Object.(*object.Integer); ok {
+		return obj
+	}
+	return newInteger(i.Value)
+}
+
+func evalBigIntegerLiteral(
+	i *ast.BigIntegerLiteral, env *object.Environment,
+) object.Object {
+	if obj, ok := i.Object.(object.Object); ok {
+		return obj
 	}
+	return normalizeBigInteger(new(big.Int).Set(i.Value))
 }
 
 func evalIdentifier(
 	i *ast.Identifier, env *object.Environment,
 ) object.Object {
-	if obj, ok := env.Get(i.Value); ok {
+	if obj, ok := env.GetSlot(i.Value, i.Depth, i.Index); ok {
 		return obj
 	}
 
@@ -117,33 +182,142 @@ func evalIdentifier(
 func evalArithmeticExpression(
 	op string, left, right object.Object,
 ) object.Object {
-	value := &object.Integer{}
+	if err := expectNumericObject(left); err != nil {
+		return err
+	}
 
-	l, err := expectIntegerObject(left)
-	if err != nil {
+	if err := expectNumericObject(right); err != nil {
 		return err
 	}
 
-	r, err := expectIntegerObject(right)
-	if err != nil {
+	if err := checkArithmeticOperands(op, left, right); err != nil {
 		return err
 	}
 
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		if value, ok := evalIntegerArithmetic(op, l.Value, r.Value); ok {
+			return newInteger(value)
+		}
+		// overflow, fall back to arbitrary precision
+	}
+
+	return evalBigIntegerArithmetic(op, left, right)
+}
+
+// Performs the integer operation op on l and r.  Returns false if the result
+// overflows an int64.
+func evalIntegerArithmetic(op string, l, r int64) (int64, bool) {
 	switch op {
 	case "+":
-		value.Value = l.Value + r.Value
+		value := l + r
+		return value, (value > l) == (r > 0)
 	case "-":
-		value.Value = l.Value - r.Value
+		value := l - r
+		return value, (value < l) == (r > 0)
 	case "*":
-		value.Value = l.Value * r.Value
+		return multiplyIntegers(l, r)
 	case "/":
-		if r.Value == 0 {
-			return evalError(divisionByZero(left, right))
+		if l == math.MinInt64 && r == -1 {
+			return 0, false
+		}
+		return l / r, true
+	case "%":
+		return l % r, true
+	case "**":
+		return exponentiateIntegers(l, r)
+	case "&":
+		return l & r, true
+	case "|":
+		return l | r, true
+	case "^":
+		return l ^ r, true
+	case "<<":
+		if r >= 64 {
+			return 0, l == 0
+		}
+		value := l << r
+		return value, value>>r == l
+	case ">>":
+		if r >= 64 {
+			r = 63
 		}
-		value.Value = l.Value / r.Value
+		return l >> r, true
 	}
+	return 0, false
+}
 
-	return value
+// Returns l * r.  Returns false if the result overflows an int64.
+func multiplyIntegers(l, r int64) (int64, bool) {
+	if l == 0 || r == 0 {
+		return 0, true
+	}
+	if (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+		return 0, false
+	}
+	value := l * r
+	return value, value/r == l
+}
+
+// Returns l raised to the power r using exponentiation by squaring.  Returns
+// false if the result overflows an int64.
+func exponentiateIntegers(l, r int64) (int64, bool) {
+	var ok bool
+	value := int64(1)
+
+	for r > 0 {
+		if r&1 == 1 {
+			if value, ok = multiplyIntegers(value, l); !ok {
+				return 0, false
+			}
+		}
+		r >>= 1
+		if r > 0 {
+			if l, ok = multiplyIntegers(l, l); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return value, true
+}
+
+// Performs the operation op on left and right using arbitrary precision.  The
+// result is demoted to an object.Integer when it fits.
+func evalBigIntegerArithmetic(
+	op string, left, right object.Object,
+) object.Object {
+	l := toBigInteger(left)
+	r := toBigInteger(right)
+	value := new(big.Int)
+
+	switch op {
+	case "+":
+		value.Add(l, r)
+	case "-":
+		value.Sub(l, r)
+	case "*":
+		value.Mul(l, r)
+	case "/":
+		value.Quo(l, r)
+	case "%":
+		value.Rem(l, r)
+	case "**":
+		value.Exp(l, r, nil)
+	case "&":
+		value.And(l, r)
+	case "|":
+		value.Or(l, r)
+	case "^":
+		value.Xor(l, r)
+	case "<<":
+		value.Lsh(l, uint(r.Uint64()))
+	case ">>":
+		value.Rsh(l, uint(r.Uint64()))
+	}
+
+	return normalizeBigInteger(value)
 }
 
 func evalAssignmentExpression(
@@ -157,13 +331,27 @@ func evalAssignmentExpression(
 	}
 
 	right := Eval(ae.Right, env)
-	if checkEvalError(right) {
+	if checkEvalInterrupt(right) {
 		return right
 	}
 
 	switch ae.Operator {
 	case "=":
-		obj, _ := env.Update(id.Value, right)
+		obj, _ := env.UpdateSlot(id.Value, id.Depth, id.Index, right)
+		return obj
+	case "+=", "-=", "*=", "/=", "%=":
+		left := evalIdentifier(id, env)
+		if checkEvalError(left) {
+			return left
+		}
+
+		op := ae.Operator[:len(ae.Operator)-1]
+		value := evalArithmeticExpression(op, left, right)
+		if checkEvalError(value) {
+			return value
+		}
+
+		obj, _ := env.UpdateSlot(id.Value, id.Depth, id.Index, value)
 		return obj
 	default:
 		return evalError(fmt.Sprintf("ERROR: invalid operator=%q (%+v)",
@@ -171,9 +359,32 @@ func evalAssignmentExpression(
 	}
 }
 
+func evalConditionalExpression(
+	ce *ast.ConditionalExpression, env *object.Environment,
+) object.Object {
+	obj := Eval(ce.Condition, env)
+	if checkEvalInterrupt(obj) {
+		return obj
+	}
+
+	condition, ok := obj.(*object.Boolean)
+	if !ok {
+		e := fmt.Sprintf(
+			"ERROR: ?: condition must evaluate to a bool. got=%T",
+			obj)
+		return evalError(e)
+	}
+
+	if condition.Value {
+		return Eval(ce.Consequence, env)
+	}
+	return Eval(ce.Alternative, env)
+}
+
 func evalEqualityExpression(
 	op string, left, right object.Object,
 ) object.Object {
+	left, right = promoteNumericOperands(left, right)
 	if left.Type() != right.Type() {
 		return mixedTypeError(op, left, right)
 	}
@@ -191,49 +402,106 @@ func evalEqualityExpression(
 func evalFunctionCallExpression(
 	node *ast.FunctionCallExpression, env *object.Environment,
 ) object.Object {
+	function, args, err := evalCall(node, env)
+	if err != nil {
+		return err
+	}
+
+	return callFunction(node, function, args, env.Hook())
+}
+
+// Evaluates the function and arguments of a call.  Returns an error object if
+// either fails or the function is not a function.
+func evalCall(
+	node *ast.FunctionCallExpression, env *object.Environment,
+) (*object.Function, []object.Object, object.Object) {
 	function := Eval(node.Function, env)
-	if checkEvalError(function) {
-		return function
+	if checkEvalInterrupt(function) {
+		return nil, nil, function
 	}
 
 	args := evalFunctionCallArguments(node.Arguments, env)
-	if len(args) == 1 && checkEvalError(args[0]) {
-		return args[0]
+	if len(args) == 1 && checkEvalInterrupt(args[0]) {
+		return nil, nil, args[0]
 	}
 
-	switch function := function.(type) {
-	case *object.Function:
-		extendedEnv := object.NewScopedEnvironment(function.Env)
+	f, ok := function.(*object.Function)
+	if !ok {
+		return nil, nil, evalError(fmt.Sprintf("not a function: %s",
+			function.Type()))
+	}
+
+	return f, args, nil
+}
+
+// Calls function with args and returns the result.  A tail call returned by
+// the function's body is made in place of the call that returned it rather
+// than within it, so tail recursion runs in constant stack space.
+func callFunction(
+	node *ast.FunctionCallExpression,
+	function *object.Function,
+	args []object.Object,
+	hook object.Hook,
+) object.Object {
+	for {
+		extendedEnv := object.NewFunctionEnvironment(function.Env)
 		prepareFunctionCallParameters(
 			args, function.Parameters, extendedEnv)
 
-		evaluated := Eval(function.Body, extendedEnv)
-		if ret, ok := evaluated.(*object.Return); ok {
-			return ret.Value
+		// the call is made with the caller's hook, even when it has none
+		extendedEnv.SetHook(hook)
+		if hook != nil {
+			hook.EnterCall(node, function, extendedEnv)
 		}
-		return evaluated
 
-	default:
-		return evalError(fmt.Sprintf("not a function: %s",
-			function.Type()))
+		result := evalFunctionBody(function.Body, extendedEnv)
+
+		tc := tailCall(result)
+		if tc == nil {
+			result = functionResult(result)
+			if hook != nil {
+				hook.ExitCall(node, result)
+			}
+			return result
+		}
+
+		if hook != nil {
+			hook.ExitCall(node, nil)
+		}
+		node, function, args = tc.Node, tc.Function, tc.Arguments
 	}
 }
 
+// Returns the tail call returned by obj, or nil if obj is not a return of a
+// tail call.
+func tailCall(obj object.Object) *object.TailCall {
+	if r, ok := obj.(*object.Return); ok {
+		tc, _ := r.Value.(*object.TailCall)
+		return tc
+	}
+	return nil
+}
+
 func evalInfixExpression(
 	ie *ast.InfixExpression, env *object.Environment,
 ) object.Object {
+	switch ie.Operator {
+	case "&&", "||":
+		return evalLogicalExpression(ie, env)
+	}
+
 	left := Eval(ie.Left, env)
-	if checkEvalError(left) {
+	if checkEvalInterrupt(left) {
 		return left
 	}
 
 	right := Eval(ie.Right, env)
-	if checkEvalError(right) {
+	if checkEvalInterrupt(right) {
 		return right
 	}
 
 	switch ie.Operator {
-	case "+", "-", "*", "/":
+	case "+", "-", "*", "/", "%", "**", "&", "|", "^", "<<", ">>":
 		return evalArithmeticExpression(ie.Operator, left, right)
 	case "==", "!=":
 		return evalEqualityExpression(ie.Operator, left, right)
@@ -245,20 +513,161 @@ func evalInfixExpression(
 	}
 }
 
+// Evaluates && and || with short-circuit semantics.  The right operand is only
+// evaluated when the left operand does not determine the result.
+func evalLogicalExpression(
+	ie *ast.InfixExpression, env *object.Environment,
+) object.Object {
+	left := Eval(ie.Left, env)
+	if checkEvalInterrupt(left) {
+		return left
+	}
+
+	l, err := expectBooleanObject(ie.Operator, left)
+	if err != nil {
+		return err
+	}
+
+	if (ie.Operator == "&&" && !l.Value) || (ie.Operator == "||" && l.Value) {
+		return l
+	}
+
+	right := Eval(ie.Right, env)
+	if checkEvalInterrupt(right) {
+		return right
+	}
+
+	r, err := expectBooleanObject(ie.Operator, right)
+	if err != nil {
+		return err
+	}
+
+	return r
+}
+
+// Evaluates ++ and -- applied to operand.  The updated value is returned for
+// prefix forms and the original value for postfix forms.
+func evalIncrementExpression(
+	op string, operand ast.Expression, prefix bool, env *object.Environment,
+) object.Object {
+	id, ok := operand.(*ast.Identifier)
+	if !ok {
+		return evalError(
+			fmt.Sprintf("runtime error. identifier expected (%+v)",
+				operand))
+	}
+
+	current := evalIdentifier(id, env)
+	if checkEvalError(current) {
+		return current
+	}
+
+	value := evalArithmeticExpression(op[:1], current,
+		newInteger(1))
+	if checkEvalError(value) {
+		return value
+	}
+
+	if obj, ok := env.UpdateSlot(id.Value, id.Depth, id.Index, value); !ok {
+		return obj
+	}
+
+	if prefix {
+		return value
+	}
+	return current
+}
+
+func evalPostfixExpression(
+	pe *ast.PostfixExpression, env *object.Environment,
+) object.Object {
+	return evalIncrementExpression(pe.Operator, pe.Left, false, env)
+}
+
+// Evaluates the body of the first arm whose pattern matches the subject and
+// whose guard, if any, evaluates to true.  Bindings introduced by a pattern
+// are scoped to its arm.
+func evalMatchExpression(
+	me *ast.MatchExpression, env *object.Environment,
+) object.Object {
+	subject := Eval(me.Subject, env)
+	if checkEvalInterrupt(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		local := object.NewScopedEnvironment(env)
+
+		matched, err := matchPattern(arm.Pattern, subject, local)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			obj := Eval(arm.Guard, local)
+			if checkEvalInterrupt(obj) {
+				return obj
+			}
+
+			guard, ok := obj.(*object.Boolean)
+			if !ok {
+				e := fmt.Sprintf("ERROR: match guard must "+
+					"evaluate to a bool. got=%T", obj)
+				return evalError(e)
+			}
+			if !guard.Value {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, local)
+	}
+
+	e := fmt.Sprintf("ERROR: no match arm for value=%s", subject.Inspect())
+	return evalError(e)
+}
+
 func evalPrefixExpression(
 	pe *ast.PrefixExpression, env *object.Environment,
 ) object.Object {
+	switch pe.Operator {
+	case "++", "--":
+		return evalIncrementExpression(pe.Operator, pe.Right, true, env)
+	}
+
 	result := Eval(pe.Right, env)
+	if checkEvalInterrupt(result) {
+		return result
+	}
 
 	switch obj := result.(type) {
 	case *object.Integer:
-		if pe.Operator != "-" {
-			e := fmt.Sprintf(
-				"ERROR: unsupported operator=%q node=%T (%+v)",
-				pe.Operator, result, result)
-			return evalError(e)
+		switch pe.Operator {
+		case "-":
+			if obj.Value == math.MinInt64 {
+				return normalizeBigInteger(
+					new(big.Int).Neg(big.NewInt(obj.Value)))
+			}
+			return newInteger(-obj.Value)
+		case "~":
+			return newInteger(^obj.Value)
+		}
+		e := fmt.Sprintf("ERROR: unsupported operator=%q node=%T (%+v)",
+			pe.Operator, result, result)
+		return evalError(e)
+	case *object.BigInt:
+		switch pe.Operator {
+		case "-":
+			return normalizeBigInteger(new(big.Int).Neg(obj.Value))
+		case "~":
+			return normalizeBigInteger(new(big.Int).Not(obj.Value))
 		}
-		return &object.Integer{Value: -obj.Value}
+		e := fmt.Sprintf("ERROR: unsupported operator=%q node=%T (%+v)",
+			pe.Operator, result, result)
+		return evalError(e)
 	case *object.Boolean:
 		if pe.Operator != "!" {
 			e := fmt.Sprintf(
@@ -266,7 +675,7 @@ func evalPrefixExpression(
 				pe.Operator, result, result)
 			return evalError(e)
 		}
-		return &object.Boolean{Value: !obj.Value}
+		return evalBooleanObject(!obj.Value)
 	default:
 		return evalError(
 			fmt.Sprintf("ERROR: unsupported node=%T (%+v)",
@@ -277,6 +686,7 @@ func evalPrefixExpression(
 func evalRelationalExpression(
 	op string, left, right object.Object,
 ) object.Object {
+	left, right = promoteNumericOperands(left, right)
 	if left.Type() != right.Type() {
 		return mixedTypeError(op, left, right)
 	}
@@ -300,33 +710,48 @@ func evalRelationalExpression(
 // Statement evaluators
 // ----------------------------------------------------------------------------
 
+// Evaluates the statements in the block and returns the value of the last
+// one, or the return or error object of the first return statement or error
+// encountered.  The caller is responsible for providing the block's scope:
+// Eval creates a new one for every block, while function calls reuse the scope
+// of their parameters.
 func evalBlockStatement(
 	node *ast.BlockStatement,
 	env *object.Environment,
 ) object.Object {
+	var result object.Object = NULL
+
 	for _, statement := range node.Statements {
-		obj := Eval(statement, env)
-		if obj.Type() == object.RETURN_OBJ {
-			return obj
+		if hook := env.Hook(); hook != nil {
+			hook.Statement(statement, env)
+		}
+
+		result = Eval(statement, env)
+		switch result.Type() {
+		case object.RETURN_OBJ, object.ERROR_OBJ:
+			return result
 		}
 	}
-	return NULL
+	return result
 }
 
 func evalDeclarationStatement(
 	node *ast.VariableDeclarationStatement, env *object.Environment,
 ) object.Object {
 	val := Eval(node.Value, env)
-	if checkEvalError(val) {
+	if checkEvalInterrupt(val) {
 		return val
 	}
 
-	if _, exists := env.Get(node.Name.Value); exists {
-		e := fmt.Sprintf("ERROR: identifier=%q already defined.",
-			node.Name.Value)
-		return evalError(e)
+	if _, exists := env.GetLocal(node.Name.Value); exists {
+		return alreadyDefinedError(node.Name.Value)
+	}
+
+	if node.Constant() {
+		env.SetConstant(node.Name.Value, val)
+	} else {
+		env.Set(node.Name.Value, val)
 	}
-	env.Set(node.Name.Value, val)
 	return NULL
 }
 
@@ -335,6 +760,11 @@ func evalFunctionDeclarationStatement(
 ) object.Object {
 	var function object.Function
 
+	if _, exists := env.GetLocal(node.Name.Value); exists {
+		return alreadyDefinedError(node.Name.Value)
+	}
+
+	function.Name = node.Name.Value
 	function.Parameters = node.Parameters
 	function.Body = node.Body
 	function.Env = env
@@ -343,11 +773,38 @@ func evalFunctionDeclarationStatement(
 	return NULL
 }
 
+// Returns the value a call produces when its body evaluates to obj: the value
+// of a return statement or an error, and NULL otherwise.
+func functionResult(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Return:
+		return obj.Value
+	case *object.Error:
+		return obj
+	}
+	return NULL
+}
+
+// Evaluates a function body in env, the scope holding the call's parameters.
+// The body's block does not introduce a scope of its own, so its declarations
+// share a scope with the parameters.
+func evalFunctionBody(
+	body ast.Statement, env *object.Environment,
+) object.Object {
+	if block, ok := body.(*ast.BlockStatement); ok {
+		return evalBlockStatement(block, env)
+	}
+	return Eval(body, env)
+}
+
 func evalIfStatement(
 	node *ast.IfStatement,
 	env *object.Environment,
 ) object.Object {
 	obj := Eval(node.Condition, env)
+	if checkEvalInterrupt(obj) {
+		return obj
+	}
 
 	condition, ok := obj.(*object.Boolean)
 	if !ok {
@@ -358,13 +815,11 @@ func evalIfStatement(
 	}
 
 	if condition.Value {
-		local := object.NewScopedEnvironment(env)
-		return Eval(node.Consequence, local)
+		return Eval(node.Consequence, env)
 	}
 
 	if node.Alternative != nil {
-		local := object.NewScopedEnvironment(env)
-		return Eval(node.Alternative, local)
+		return Eval(node.Alternative, env)
 	}
 
 	return NULL
@@ -373,8 +828,21 @@ func evalIfStatement(
 func evalReturnStatement(node *ast.ReturnStatement,
 	env *object.Environment,
 ) object.Object {
+	// a returned call is made by the caller, once this call has returned.
+	// Outside of a function there is no caller, so it is made here.
+	call, ok := node.ReturnValue.(*ast.FunctionCallExpression)
+	if ok && env.InFunction() {
+		function, args, err := evalCall(call, env)
+		if err != nil {
+			return err
+		}
+
+		tc := &object.TailCall{Node: call, Function: function, Arguments: args}
+		return &object.Return{Value: tc}
+	}
+
 	val := Eval(node.ReturnValue, env)
-	if checkEvalError(val) {
+	if checkEvalInterrupt(val) {
 		return val
 	}
 
@@ -387,7 +855,25 @@ func evalStatements(
 	var result object.Object
 
 	for _, statement := range statements {
+		if hook := env.Hook(); hook != nil {
+			hook.Statement(statement, env)
+		}
+
 		result = Eval(statement, env)
+
+		// a program evaluated in the environment of a call, as by the
+		// debugger, has no caller to make a tail call it returns
+		if tc := tailCall(result); tc != nil {
+			result = callFunction(tc.Node, tc.Function, tc.Arguments,
+				env.Hook())
+			if !checkEvalError(result) {
+				result = &object.Return{Value: result}
+			}
+		}
+
+		if checkEvalError(result) {
+			return result
+		}
 	}
 
 	return result
@@ -443,6 +929,64 @@ func compareIntegers(op string, left, right object.Object) object.Object {
 	return evalBooleanObject(result)
 }
 
+func compareBigIntegers(op string, left, right object.Object) object.Object {
+	l := left.(*object.BigInt)
+	r := right.(*object.BigInt)
+
+	var result bool
+
+	switch cmp := l.Value.Cmp(r.Value); op {
+	case "==":
+		result = cmp == 0
+	case "!=":
+		result = cmp != 0
+	case "<":
+		result = cmp < 0
+	case "<=":
+		result = cmp <= 0
+	case ">":
+		result = cmp > 0
+	case ">=":
+		result = cmp >= 0
+	default:
+		return evalError(
+			fmt.Sprintf("unsupported comparison operator %s", op))
+	}
+
+	return evalBooleanObject(result)
+}
+
+// Reports whether value matches pattern, binding identifiers in env.  Literal
+// patterns only match values of the same type.
+func matchPattern(
+	pattern ast.Expression, value object.Object, env *object.Environment,
+) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Wildcard:
+		return true, nil
+	case *ast.Identifier:
+		env.Set(pattern.Value, value)
+		return true, nil
+	}
+
+	literal := Eval(pattern, env)
+	if checkEvalError(literal) {
+		return false, literal
+	}
+
+	literal, value = promoteNumericOperands(literal, value)
+	if literal.Type() != value.Type() {
+		return false, nil
+	}
+
+	result := evalEqualityExpression("==", literal, value)
+	if checkEvalError(result) {
+		return false, result
+	}
+
+	return result == TRUE, nil
+}
+
 // Evaluates the function call argument expressions and returns them in a slice
 // to be set in the functions local environment scope.
 func evalFunctionCallArguments(
@@ -452,7 +996,7 @@ func evalFunctionCallArguments(
 
 	for _, arg := range args {
 		e := Eval(arg, env)
-		if checkEvalError(e) {
+		if checkEvalInterrupt(e) {
 			return []object.Object{e}
 		}
 		obj = append(obj, e)
@@ -472,16 +1016,116 @@ func prepareFunctionCallParameters(
 	}
 }
 
-// Checks of obj is an object.Integer type and returns the object.Integer form.
-// Otherwise returns nil and an objectError as the second argument.
-func expectIntegerObject(obj object.Object) (*object.Integer, object.Object) {
-	i, ok := obj.(*object.Integer)
+// Checks if obj is an object.Integer or object.BigInt.  Otherwise returns an
+// error object.
+func expectNumericObject(obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return nil
+	}
+	e := fmt.Sprintf("ERROR: expected integer, got=%T (%+v)", obj, obj)
+	return evalError(e)
+}
+
+// Validates the right operand of operators that restrict its range: division
+// and modulo by zero, negative exponents and shift counts, and exponents or
+// shift counts too large to compute.  Returns nil if the operands are valid.
+func checkArithmeticOperands(op string, left, right object.Object) object.Object {
+	r := toBigInteger(right)
+
+	switch op {
+	case "/", "%":
+		if r.Sign() == 0 {
+			return evalError(divisionByZero(op, left, right))
+		}
+	case "**", "<<", ">>":
+		if r.Sign() < 0 {
+			e := fmt.Sprintf("ERROR: negative right operand in "+
+				"expression (%s %s %s)",
+				left.Inspect(), op, right.Inspect())
+			return evalError(e)
+		}
+		if !r.IsInt64() {
+			e := fmt.Sprintf("ERROR: right operand too large in "+
+				"expression (%s %s %s)",
+				left.Inspect(), op, right.Inspect())
+			return evalError(e)
+		}
+		if resultTooLarge(op, toBigInteger(left), r.Int64()) {
+			e := fmt.Sprintf("ERROR: result too large in "+
+				"expression (%s %s %s)",
+				left.Inspect(), op, right.Inspect())
+			return evalError(e)
+		}
+	}
+
+	return nil
+}
+
+// Reports whether the result of l ** r or l << r could need more than
+// maxIntegerBits bits.  The result of l ** r needs at most r times as many
+// bits as l, and that of l << r at most r more.
+func resultTooLarge(op string, l *big.Int, r int64) bool {
+	bits := int64(l.BitLen())
+
+	switch op {
+	case "**":
+		// 0, 1 and -1 stay small whatever the exponent
+		return bits > 1 && r > maxIntegerBits/bits
+	case "<<":
+		return bits > 0 && r > maxIntegerBits-bits
+	}
+	return false
+}
+
+// Checks if obj is an object.Boolean and returns the object.Boolean form.
+// Otherwise returns nil and an error object as the second argument.
+func expectBooleanObject(
+	op string, obj object.Object,
+) (*object.Boolean, object.Object) {
+	b, ok := obj.(*object.Boolean)
 	if !ok {
-		e := fmt.Sprintf("ERROR: expected integer, got=%T (%+v)",
-			obj, obj)
+		e := fmt.Sprintf("ERROR: operator %s expected boolean, "+
+			"got=%T (%+v)", op, obj, obj)
 		return nil, evalError(e)
 	}
-	return i, nil
+	return b, nil
+}
+
+// Returns the arbitrary-precision value of an object.Integer or object.BigInt.
+func toBigInteger(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return nil
+}
+
+// Returns value as an object.Integer if it fits in an int64, otherwise as an
+// object.BigInt.
+func normalizeBigInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return newInteger(value.Int64())
+	}
+	return &object.BigInt{Value: value}
+}
+
+// When left and right are both numeric but of different representations, both
+// are promoted to object.BigInt so they can be compared.  Otherwise left and
+// right are returned unchanged.
+func promoteNumericOperands(
+	left, right object.Object,
+) (object.Object, object.Object) {
+	if left.Type() == right.Type() ||
+		expectNumericObject(left) != nil ||
+		expectNumericObject(right) != nil {
+		return left, right
+	}
+
+	return &object.BigInt{Value: toBigInteger(left)},
+		&object.BigInt{Value: toBigInteger(right)}
 }
 
 // ----------------------------------------------------------------------------
@@ -496,12 +1140,29 @@ func checkEvalError(obj object.Object) bool {
 	return false
 }
 
-func divisionByZero(l, r object.Object) string {
-	e := fmt.Sprintf("ERROR: divide by zero error in expression (%s / %s)",
-		l.Inspect(), r.Inspect())
+// Reports whether obj is an error or the result of a return statement, either
+// of which ends the evaluation of the expression it is an operand of.  A
+// return reaches an operand when a block containing it is used as a value, as
+// in var x = if (c) { return 1; } else { 2 };
+func checkEvalInterrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.Return:
+		return true
+	}
+	return false
+}
+
+func divisionByZero(op string, l, r object.Object) string {
+	e := fmt.Sprintf("ERROR: divide by zero error in expression (%s %s %s)",
+		l.Inspect(), op, r.Inspect())
 	return e
 }
 
+func alreadyDefinedError(name string) object.Object {
+	e := fmt.Sprintf("ERROR: identifier=%q already defined.", name)
+	return evalError(e)
+}
+
 func evalError(s string) object.Object {
 	e := object.Error{
 		Value: s,
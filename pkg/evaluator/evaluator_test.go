@@ -3,6 +3,7 @@ package evaluator
 import (
 	"testing"
 
+	"github.com/freddiehaddad/corrosion/pkg/ast"
 	"github.com/freddiehaddad/corrosion/pkg/lexer"
 	"github.com/freddiehaddad/corrosion/pkg/object"
 	"github.com/freddiehaddad/corrosion/pkg/parser"
@@ -16,7 +17,9 @@ func TestEvalIntegerExpressions(t *testing.T) {
 		{"5;", 5},
 		{"10;", 10},
 		{"-10;", -10},
-		{"--10;", 10},
+		{"- -10;", 10},
+		{"0xFF + 0o10 + 0b11;", 266},
+		{"1_000 * 1_000;", 1000000},
 	}
 
 	e := object.NewEnvironment()
@@ -254,6 +257,186 @@ func TestArithmeticExpressions(t *testing.T) {
 	}
 }
 
+func TestModuloExponentAndBitwiseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3;", "1"},
+		{"-7 % 3;", "-1"},
+		{"7 % -3;", "1"},
+		{"2 ** 10;", "1024"},
+		{"2 ** 3 ** 2;", "512"},
+		{"-2 ** 2;", "-4"},
+		{"(-2) ** 3;", "-8"},
+		{"5 ** 0;", "1"},
+		{"2 ** 64;", "18446744073709551616"},
+		{"6 & 3;", "2"},
+		{"6 | 3;", "7"},
+		{"6 ^ 3;", "5"},
+		{"~0;", "-1"},
+		{"~5;", "-6"},
+		{"1 << 4;", "16"},
+		{"1 << 63;", "9223372036854775808"},
+		{"1 << 64;", "18446744073709551616"},
+		{"-16 >> 2;", "-4"},
+		{"1 >> 64;", "0"},
+		{"-1 >> 64;", "-1"},
+		{"(1 << 64) >> 60;", "16"},
+		{"(1 << 64) % 10;", "6"},
+		{"(1 << 64) | 1;", "18446744073709551617"},
+		{"~(1 << 64);", "-18446744073709551617"},
+		{"255 & 15 == 15;", "true"},
+	}
+
+	for index, test := range tests {
+		e := object.NewEnvironment()
+
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, e)
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s (%T), "+
+				"expected=%s", index, result.Inspect(),
+				result, test.expected)
+		}
+	}
+}
+
+func TestArithmeticOperandErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"5 % 0;",
+			"ERROR: divide by zero error in expression (5 % 0)",
+		},
+		{
+			"2 ** -1;",
+			"ERROR: negative right operand in expression (2 ** -1)",
+		},
+		{
+			"2 << -1;",
+			"ERROR: negative right operand in expression (2 << -1)",
+		},
+		{
+			"2 >> -1;",
+			"ERROR: negative right operand in expression (2 >> -1)",
+		},
+		{
+			"2 ** 9223372036854775808;",
+			"ERROR: right operand too large in expression " +
+				"(2 ** 9223372036854775808)",
+		},
+		{
+			"1 << 100000000000;",
+			"ERROR: result too large in expression " +
+				"(1 << 100000000000)",
+		},
+		{
+			"2 ** 10000000000;",
+			"ERROR: result too large in expression " +
+				"(2 ** 10000000000)",
+		},
+		{
+			"(0 - 3) ** 1000000;",
+			"ERROR: result too large in expression (-3 ** 1000000)",
+		},
+		{
+			"9223372036854775808 << 1048513;",
+			"ERROR: result too large in expression " +
+				"(9223372036854775808 << 1048513)",
+		},
+	}
+
+	e := object.NewEnvironment()
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, e)
+
+		switch obj := result.(type) {
+		case *object.Error:
+			testErrorObject(t, obj, test.expected)
+		default:
+			t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		}
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && true;", "true"},
+		{"true && false;", "false"},
+		{"false && true;", "false"},
+		{"true || false;", "true"},
+		{"false || false;", "false"},
+		{"1 < 2 && 2 < 3;", "true"},
+		{"1 > 2 || 2 > 3;", "false"},
+		{"false && 1;", "false"},
+		{"true || 1;", "true"},
+		{"false && undefined;", "false"},
+		{"true || 1 / 0 == 1;", "true"},
+		{
+			"true && 1;",
+			"ERROR: operator && expected boolean, " +
+				"got=*object.Integer (&{Value:1})",
+		},
+		{
+			"1 || true;",
+			"ERROR: operator || expected boolean, " +
+				"got=*object.Integer (&{Value:1})",
+		},
+		{
+			"false || 1 / 0 == 1;",
+			"ERROR: divide by zero error in expression (1 / 0)",
+		},
+	}
+
+	e := object.NewEnvironment()
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, e)
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	input := `
+		var calls = 0;
+		func touch() { calls = calls + 1; return true; }
+		false && touch();
+		true || touch();
+		true && touch();
+		false || touch();
+		calls;`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	result := Eval(program, object.NewEnvironment())
+	testIntegerObject(t, 0, result, 2)
+}
+
 func TestGroupedExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -292,6 +475,86 @@ func TestGroupedExpressions(t *testing.T) {
 	}
 }
 
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1;", "9223372036854775808"},
+		{"-9223372036854775807 - 2;", "-9223372036854775809"},
+		{"9223372036854775807 * 2;", "18446744073709551614"},
+		{"-9223372036854775808 / -1;", "9223372036854775808"},
+		{"- -9223372036854775808;", "9223372036854775808"},
+		{"99999999999999999999 * 99999999999999999999;",
+			"9999999999999999999800000000000000000001"},
+		{"99999999999999999999 / 99999999999999999999;", "1"},
+		{"-99999999999999999999 / 7;", "-14285714285714285714"},
+		{"9223372036854775808 - 1;", "9223372036854775807"},
+		{"-9223372036854775808;", "-9223372036854775808"},
+	}
+
+	for index, test := range tests {
+		e := object.NewEnvironment()
+
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, e)
+
+		switch obj := result.(type) {
+		case *object.Integer, *object.BigInt:
+			if obj.Inspect() != test.expected {
+				t.Errorf("tests[%d]: wrong value. got=%s, "+
+					"expected=%s", index, obj.Inspect(),
+					test.expected)
+			}
+		default:
+			t.Errorf("tests[%d]: object is not Integer. got=%T (%+v)",
+				index, obj, obj)
+		}
+	}
+}
+
+func TestIntegerOverflowDemotion(t *testing.T) {
+	input := "9223372036854775807 + 1 - 1;"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	result := Eval(program, object.NewEnvironment())
+	testIntegerObject(t, 0, result, 9223372036854775807)
+}
+
+func TestBigIntegerComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775808 == 9223372036854775808;", true},
+		{"9223372036854775808 != 9223372036854775808;", false},
+		{"9223372036854775808 > 9223372036854775807;", true},
+		{"9223372036854775807 < 9223372036854775808;", true},
+		{"9223372036854775807 + 1 == 9223372036854775808;", true},
+		{"-9223372036854775809 <= 0;", true},
+		{"0 >= -9223372036854775809;", true},
+		{"5 == 9223372036854775808;", false},
+		{"9223372036854775808 != 5;", true},
+	}
+
+	e := object.NewEnvironment()
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, e)
+		testBooleanObject(t, index, result, test.expected)
+	}
+}
+
 func TestArithmeticExpressionsWithVariables(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -342,6 +605,11 @@ func TestDivideByZeroError(t *testing.T) {
 			"1 / 0 + 2;",
 			"ERROR: divide by zero error in expression (1 / 0)",
 		},
+		{
+			"9223372036854775808 / 0;",
+			"ERROR: divide by zero error in expression " +
+				"(9223372036854775808 / 0)",
+		},
 	}
 
 	e := object.NewEnvironment()
@@ -373,6 +641,9 @@ func TestVariableDeclaration(t *testing.T) {
 		{"y + x;", 5},
 		{"x - y;", 1},
 		{"y - x;", -1},
+		{"var user_id = 7; user_id;", 7},
+		{"var x1 = 4; var _x = x1 * 2; _x;", 8},
+		{"var größe = 5; var 名前 = größe + 1; 名前;", 6},
 	}
 
 	e := object.NewEnvironment()
@@ -422,6 +693,158 @@ func TestAssignment(t *testing.T) {
 	}
 }
 
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x = 4; x += 5; x;", 9},
+		{"var x = 4; x -= 5; x;", -1},
+		{"var x = 4; x *= 5; x;", 20},
+		{"var x = 20; x /= 3; x;", 6},
+		{"var x = 20; x %= 3; x;", 2},
+		{"var x = 4; x += 2 * 3; x;", 10},
+		{"var x = 1; var y = 2; x += y += 3; x;", 6},
+		{"var x = 1; var y = 2; x += y += 3; y;", 5},
+		{"var x = 1; x += 1;", 2},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		e := object.NewEnvironment()
+
+		result := Eval(program, e)
+		testIntegerObject(t, index, result, test.expected)
+	}
+}
+
+func TestIncrementDecrement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x = 4; x++; x;", 5},
+		{"var x = 4; x--; x;", 3},
+		{"var x = 4; ++x; x;", 5},
+		{"var x = 4; --x; x;", 3},
+		{"var x = 4; x++;", 4},
+		{"var x = 4; x--;", 4},
+		{"var x = 4; ++x;", 5},
+		{"var x = 4; --x;", 3},
+		{"var x = 4; x++ + x;", 9},
+		{"var x = 4; ++x + x;", 10},
+		{"var x = 4; -x++;", -4},
+		{"func f() { var i = 0; i++; i++; return i; } f();", 2},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		e := object.NewEnvironment()
+
+		result := Eval(program, e)
+		testIntegerObject(t, index, result, test.expected)
+	}
+}
+
+func TestCompoundAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x += 1;", "ERROR: undefined identifier=\"x\" (x)"},
+		{"x++;", "ERROR: undefined identifier=\"x\" (x)"},
+		{
+			"var x = 1; x /= 0;",
+			"ERROR: divide by zero error in expression (1 / 0)",
+		},
+		{
+			"var x = true; x++;",
+			"ERROR: expected integer, got=*object.Boolean " +
+				"(&{Value:true})",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		e := object.NewEnvironment()
+
+		result := Eval(program, e)
+
+		switch obj := result.(type) {
+		case *object.Error:
+			testErrorObject(t, obj, test.expected)
+		default:
+			t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		}
+	}
+}
+
+func TestConstantDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 5; x;", "5"},
+		{"const x = 5; var y = x * 2; y;", "10"},
+		{
+			"func f() { limit = 5; } const limit = 1; f();",
+			"ERROR: cannot assign to constant \"limit\"",
+		},
+		{
+			"func f() { limit++; } const limit = 1; f();",
+			"ERROR: cannot assign to constant \"limit\"",
+		},
+		{
+			"const x = 5; var x = 6;",
+			"ERROR: identifier=\"x\" already defined.",
+		},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		result := Eval(program, object.NewEnvironment())
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
+// The program stops at the failed assignment, so the constant is read by a
+// second program evaluated in the same environment.
+func TestConstantUnchangedAfterFailedAssignment(t *testing.T) {
+	e := object.NewEnvironment()
+
+	l := lexer.New("func f() { limit = 5; } const limit = 1; f();")
+	p := parser.New(l)
+	result := Eval(p.ParseProgram(), e)
+
+	expected := "ERROR: cannot assign to constant \"limit\""
+	if result.Inspect() != expected {
+		t.Errorf("wrong value. got=%s, expected=%s",
+			result.Inspect(), expected)
+	}
+
+	l = lexer.New("limit;")
+	p = parser.New(l)
+	result = Eval(p.ParseProgram(), e)
+
+	testIntegerObject(t, 0, result, 1)
+}
+
 func TestIfStatements(t *testing.T) {
 	input := `
 		var x = 3;
@@ -447,6 +870,152 @@ func TestIfStatements(t *testing.T) {
 	}
 }
 
+func TestIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var x = if (true) { 1 } else { 2 }; x;", "1"},
+		{"var x = if (false) { 1 } else { 2 }; x;", "2"},
+		{"var x = if (false) { 1 }; x;", "null"},
+		{"var a = 3; var x = if (a > 2) { var b = a * 2; b + 1 }; x;", "7"},
+		{"var x = if (true) { 1; 2; 3; }; x;", "3"},
+		{"(if (true) { 5 } else { 6 }) * 2;", "10"},
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{
+			"func f(a) { return if (a) { 1 } else { 2 }; } f(false);",
+			"2",
+		},
+		{"func f() { if (true) { 1 } } f();", "null"},
+		{"func f() { if (true) { return 1; } 2; } f();", "1"},
+
+		// a return in a branch used as a value returns from the function
+		{
+			"func f() { var x = if (true) { return 1; } else { 2 }; " +
+				"return 5; } f();",
+			"1",
+		},
+		{
+			"func f() { var x = 0; x = if (true) { return 1; }; " +
+				"return x; } f();",
+			"1",
+		},
+		{"func f() { 1 + if (true) { return 2; }; 3; } f();", "2"},
+		{"func f() { -(if (true) { return 2; }); 3; } f();", "2"},
+		{"func f() { true && if (true) { return 2; }; 3; } f();", "2"},
+		{
+			"func g(n) { return n + 1; } " +
+				"func f() { g(if (true) { return 2; }); return 3; } f();",
+			"2",
+		},
+		{
+			"func f() { if (if (true) { return 2; }) { 3; } 4; } f();",
+			"2",
+		},
+		{
+			"func f() { match (if (true) { return 2; }) { _ => 3 }; } f();",
+			"2",
+		},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		result := Eval(program, object.NewEnvironment())
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
+func TestConditionalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true ? 1 : 2;", "1"},
+		{"false ? 1 : 2;", "2"},
+		{"1 < 2 ? 10 : 20;", "10"},
+		{"false ? 1 : true ? 2 : 3;", "2"},
+		{"false ? 1 : false ? 2 : 3;", "3"},
+		{"var x = 5; var y = x > 3 ? x * 2 : x; y;", "10"},
+		{"true ? 1 : 1 / 0;", "1"},
+		{"false ? 1 / 0 : 2;", "2"},
+		{
+			"1 ? 2 : 3;",
+			"ERROR: ?: condition must evaluate to a bool. " +
+				"got=*object.Integer",
+		},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		result := Eval(program, object.NewEnvironment())
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (1) { 1 => 10, 2 => 20, _ => 0 }", "10"},
+		{"match (2) { 1 => 10, 2 => 20, _ => 0 }", "20"},
+		{"match (3) { 1 => 10, 2 => 20, _ => 0 }", "0"},
+		{"match (-2) { -2 => 1, _ => 0 }", "1"},
+		{"match (1 < 2) { true => 1, false => 2 }", "1"},
+		{"match (5) { true => 1, 5 => 2 }", "2"},
+		{"match (5) { n => n * 2 }", "10"},
+		{"match (5) { _n => _n * 2 }", "10"},
+		{"match (5) { n if n > 3 => 1, n => 2 }", "1"},
+		{"match (2) { n if n > 3 => 1, n => 2 }", "2"},
+		{"match (4) { n if n % 2 == 0 => { var h = n / 2; h }, _ => 0 }",
+			"2"},
+		{"var x = match (9223372036854775808) { " +
+			"9223372036854775808 => true, _ => false }; x;", "true"},
+		{"match (7) { 1 => 1 }", "ERROR: no match arm for value=7"},
+		{"match (1) { n if n => 1 }",
+			"ERROR: match guard must evaluate to a bool. " +
+				"got=*object.Integer"},
+		{"func f(x) { match (x) { 0 => { return 1; }, _ => 2 } return 3; } " +
+			"f(0);", "1"},
+		{"func f(x) { match (x) { 0 => { return 1; }, _ => 2 } return 3; } " +
+			"f(1);", "3"},
+		{"var n = 1; match (5) { n => n }; n;", "1"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		result := Eval(program, object.NewEnvironment())
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
 func TestFunctionCalls(t *testing.T) {
 	input := `
 		var val = 3;
@@ -475,6 +1044,158 @@ func TestFunctionCalls(t *testing.T) {
 	}
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// deep enough to exhaust the stack without tail calls
+		{"func count(n, acc) { if (n == 0) { return acc; } " +
+			"return count(n - 1, acc + 1); } count(1000000, 0);", "1000000"},
+		{"func even(n) { if (n == 0) { return true; } return odd(n - 1); } " +
+			"func odd(n) { if (n == 0) { return false; } return even(n - 1); } " +
+			"even(1000001);", "false"},
+		{"func f(n) { return n * 2; } func g(n) { return f(n + 1); } " +
+			"return g(4);", "10"},
+		{"func f(n) { return n / 0; } func g(n) { return f(n); } g(1);",
+			"ERROR: divide by zero error in expression (1 / 0)"},
+		{"func g(n) { return h(n); } g(1);",
+			`ERROR: undefined identifier="h" (h)`},
+		{"var h = 1; func g(n) { return h(n); } g(1);",
+			"not a function: INTEGER"},
+		{"func g(n) { return f(n / 0); } func f(n) { return n; } g(1);",
+			"ERROR: divide by zero error in expression (1 / 0)"},
+
+		// tail calls returned from branches used as values
+		{"func g() { return 7; } func h() { var x = if (true) { " +
+			"return g(); } else { 2 }; return x; } h();", "7"},
+		{"func g() { return 7; } func h() { var x = if (true) { " +
+			"return g(); } else { 2 }; return x; } h() + 1;", "8"},
+		{"func g() { return 7; } func h() { var x = 0; " +
+			"x = if (true) { return g(); }; return x; } h() * 2;", "14"},
+		{"func g() { return 7; } func h() { return 1 + " +
+			"if (true) { return g(); } else { 0 }; } h();", "7"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		result := Eval(program, object.NewEnvironment())
+
+		if result.Inspect() != test.expected {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, result.Inspect(), test.expected)
+		}
+	}
+}
+
+// Statements evaluated one at a time outside of any function, as by the REPL,
+// make the calls they return.
+func TestTailCallsOutsideFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func g() { return 7; } return g();", "7"},
+		{"func g() { return 7; } if (true) { return g(); }", "7"},
+		{"func g() { return 7; } var x = if (true) { return g(); };", "7"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		env := object.NewEnvironment()
+		var result object.Object
+		for _, statement := range program.Statements {
+			result = Eval(statement, env)
+		}
+
+		r, ok := result.(*object.Return)
+		if !ok {
+			t.Fatalf("tests[%d]: expected *object.Return got=%T",
+				index, result)
+		}
+		if r.Value.Inspect() != test.expected || tailCall(r) != nil {
+			t.Errorf("tests[%d]: wrong value. got=%T (%s), expected=%s",
+				index, r.Value, r.Value.Inspect(), test.expected)
+		}
+	}
+}
+
+// Hook that tracks the depth of calls and counts statements.
+type depthHook struct {
+	depth, deepest, calls, statements int
+}
+
+func (h *depthHook) Statement(ast.Statement, *object.Environment) {
+	h.statements++
+}
+
+func (h *depthHook) EnterCall(
+	*ast.FunctionCallExpression, *object.Function, *object.Environment,
+) {
+	h.calls++
+	h.depth++
+	h.deepest = max(h.deepest, h.depth)
+}
+
+func (h *depthHook) ExitCall(*ast.FunctionCallExpression, object.Object) {
+	h.depth--
+}
+
+func TestTailCallsReplaceFrames(t *testing.T) {
+	input := `func count(n) { if (n == 0) { return 0; } return count(n - 1); }
+	func twice(n) { var once = count(n); return count(n); }
+	twice(10);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	h := &depthHook{}
+	env := object.NewEnvironment()
+	env.SetHook(h)
+	Eval(program, env)
+
+	if h.calls != 23 || h.depth != 0 || h.deepest != 2 {
+		t.Errorf("expected 23 calls, depth 0 and deepest 2. "+
+			"got=%d calls, depth %d and deepest %d",
+			h.calls, h.depth, h.deepest)
+	}
+}
+
+// A call is notified to the hook of its caller, not of the scope the function
+// was declared in, so a closure created while a hook was set no longer
+// notifies it once the hook is removed.
+func TestHookClearedForClosures(t *testing.T) {
+	env := object.NewEnvironment()
+	h := &depthHook{}
+
+	l := lexer.New("func mk() { func inner() { return 1; } return inner; } " +
+		"var f = mk();")
+	p := parser.New(l)
+	env.SetHook(h)
+	Eval(p.ParseProgram(), env)
+	env.SetHook(nil)
+
+	l = lexer.New("f();")
+	p = parser.New(l)
+	result := Eval(p.ParseProgram(), env)
+
+	testIntegerObject(t, 0, result, 1)
+	if h.calls != 1 || h.statements != 4 {
+		t.Errorf("expected 1 call and 4 statements notified. "+
+			"got=%d calls and %d statements", h.calls, h.statements)
+	}
+}
+
 func testBooleanObject(
 	t *testing.T, index int, obj object.Object, expected bool,
 ) {
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+)
+
+// ----------------------------------------------------------------------------
+// Identifier resolution
+// ----------------------------------------------------------------------------
+
+// Resolve records in each identifier in program the position of the binding
+// it refers to, as the number of scopes out from the one the identifier is
+// evaluated in and the index of the binding in that scope (see
+// object.Environment.GetSlot), and in each integer literal the object it
+// evaluates to.  Eval resolves a program before evaluating it.
+//
+// The scopes mirror those the evaluator creates, with the bindings of each
+// numbered in the order of their declarations.  An identifier refers to the
+// nearest scope declaring its name anywhere, not just before the identifier,
+// since a function may be called after a later declaration.  The environment
+// falls back to a lookup by name when the binding has yet to be declared, so
+// a resolution only ever saves work.  Identifiers declared outside of the
+// program, such as those of earlier REPL input, are always looked up by name.
+func Resolve(program *ast.Program) {
+	r := resolver{}
+	r.push()
+	r.declareStatements(program.Statements)
+	r.statements(program.Statements)
+	r.pop()
+}
+
+// Resolves program unless it has already been resolved by Eval, so that a
+// program is only modified before it is first evaluated.  Evaluations of the
+// program in other goroutines wait for the resolution to finish.
+func resolveOnce(program *ast.Program) {
+	program.Resolution.Do(func() { Resolve(program) })
+}
+
+// Each scope maps the names it declares to their index.
+type resolver struct {
+	scopes []map[string]int
+}
+
+func (r *resolver) push() {
+	r.scopes = append(r.scopes, map[string]int{})
+}
+
+func (r *resolver) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// Gives name the next index in the current scope unless it already has one.
+func (r *resolver) declare(name string) {
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[name]; !ok {
+		scope[name] = len(scope)
+	}
+}
+
+// Declares the variables and functions declared by statements, which share a
+// scope.
+func (r *resolver) declareStatements(statements []ast.Statement) {
+	for _, s := range statements {
+		switch s := s.(type) {
+		case *ast.VariableDeclarationStatement:
+			r.declare(s.Name.Value)
+		case *ast.FunctionDeclarationStatement:
+			r.declare(s.Name.Value)
+		}
+	}
+}
+
+func (r *resolver) statements(statements []ast.Statement) {
+	for _, s := range statements {
+		r.node(s)
+	}
+}
+
+func (r *resolver) node(node ast.Node) {
+	switch n := node.(type) {
+	// statements
+	case *ast.BlockStatement:
+		r.push()
+		r.declareStatements(n.Statements)
+		r.statements(n.Statements)
+		r.pop()
+	case *ast.ExpressionStatement:
+		r.node(n.Expression)
+	case *ast.FunctionDeclarationStatement:
+		// the parameters and the body share the scope of the call
+		r.push()
+		for _, parameter := range n.Parameters {
+			r.declare(parameter.Value)
+		}
+		if body, ok := n.Body.(*ast.BlockStatement); ok {
+			r.declareStatements(body.Statements)
+			r.statements(body.Statements)
+		} else {
+			r.node(n.Body)
+		}
+		r.pop()
+	case *ast.IfStatement:
+		r.node(n.Condition)
+		r.node(n.Consequence)
+		r.node(n.Alternative)
+	case *ast.ReturnStatement:
+		r.node(n.ReturnValue)
+	case *ast.VariableDeclarationStatement:
+		r.node(n.Value)
+
+	// expressions
+	case *ast.AssignmentExpression:
+		r.node(n.Left)
+		r.node(n.Right)
+	case *ast.ConditionalExpression:
+		r.node(n.Condition)
+		r.node(n.Consequence)
+		r.node(n.Alternative)
+	case *ast.FunctionCallExpression:
+		r.node(n.Function)
+		for _, argument := range n.Arguments {
+			r.node(argument)
+		}
+	case *ast.InfixExpression:
+		r.node(n.Left)
+		r.node(n.Right)
+	case *ast.PostfixExpression:
+		r.node(n.Left)
+	case *ast.PrefixExpression:
+		r.node(n.Right)
+	case *ast.MatchExpression:
+		r.node(n.Subject)
+		for i := range n.Arms {
+			// each arm binds its pattern in a scope of its own
+			arm := &n.Arms[i]
+			r.push()
+			if pattern, ok := arm.Pattern.(*ast.Identifier); ok {
+				r.declare(pattern.Value)
+			} else {
+				r.node(arm.Pattern)
+			}
+			r.node(arm.Guard)
+			r.node(arm.Body)
+			r.pop()
+		}
+	case *ast.Identifier:
+		r.identifier(n)
+
+	// literals
+	case *ast.IntegerLiteral:
+		n.Object = newInteger(n.Value)
+	case *ast.BigIntegerLiteral:
+		n.Object = normalizeBigInteger(new(big.Int).Set(n.Value))
+	}
+}
+
+// Records the position of the nearest binding of identifier.
+func (r *resolver) identifier(identifier *ast.Identifier) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		scope := r.scopes[len(r.scopes)-1-depth]
+		if index, ok := scope[identifier.Value]; ok {
+			identifier.Depth, identifier.Index = depth, index
+			return
+		}
+	}
+	identifier.Depth, identifier.Index = -1, 0
+}
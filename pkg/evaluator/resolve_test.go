@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Returns the identifiers in program as "name:depth.index", or "name:-" if
+// not resolved to a slot.
+func slots(program *ast.Program) string {
+	identifiers := []*ast.Identifier{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if identifier, ok := n.(*ast.Identifier); ok {
+			identifiers = append(identifiers, identifier)
+		}
+		return true
+	})
+
+	// declared names are left alone by Resolve, so mark them to skip them
+	for _, identifier := range identifiers {
+		identifier.Depth = -2
+	}
+	Resolve(program)
+
+	result := []string{}
+	for _, identifier := range identifiers {
+		switch identifier.Depth {
+		case -2:
+		case -1:
+			result = append(result, identifier.Value+":-")
+		default:
+			result = append(result, fmt.Sprintf("%s:%d.%d",
+				identifier.Value, identifier.Depth, identifier.Index))
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var a = 1; var b = 2; a + b;", "a:0.0 b:0.1"},
+		{"var a = 1; { var b = a; b; }", "a:1.0 b:0.0"},
+		{"func f(a, b) { var c = a; return f(b, c); }",
+			"a:0.0 f:1.0 b:0.1 c:0.2"},
+		{"var x = 1; { x; var x = 2; }", "x:0.0"},
+		{"if (t) { u; } else { var u = 1; }", "t:- u:-"},
+		{"var n = 1; match (n) { n if n > 0 => n, _ => { n; } };",
+			"n:0.0 n:0.0 n:0.0 n:2.0"},
+		{"func f() { return g(); } func g() { return 1; }", "g:1.1"},
+		{"x = 1; x++; y += x;", "x:- x:- y:- x:-"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d]: parser errors: %v", index, p.Errors())
+		}
+
+		if got := slots(program); got != test.expected {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, test.expected, got)
+		}
+	}
+}
+
+func TestResolveStaleSlots(t *testing.T) {
+	// bindings made before the program, as by earlier REPL input, shift the
+	// positions of the program's own
+	env := object.NewEnvironment()
+	env.Set("a", &object.Integer{Value: 10})
+	env.SetConstant("c", &object.Integer{Value: 100})
+
+	input := "var b = 2; b += a; func f() { return b + c; } f();"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	result := Eval(program, env)
+	testIntegerObject(t, 0, result, 112)
+
+	if b, _ := env.Get("b"); b.Inspect() != "12" {
+		t.Errorf("expected b=12 got=%s", b.Inspect())
+	}
+}
@@ -0,0 +1,248 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Conformance tests for the lexical scoping rules documented on
+// object.Environment:
+//
+//   - the program runs in the global scope
+//   - standalone blocks, if/else branches and function calls each introduce
+//     a new scope
+//   - a declaration may shadow a binding from an outer scope
+//   - redeclaring a name within the same scope is an error
+//   - functions resolve free identifiers in the scope they were declared in
+func TestScopingConformance(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// shadowing
+		{
+			"block shadows global",
+			"var x = 1; { var x = 2; } x;",
+			"1",
+		},
+		{
+			"block sees its own shadow",
+			"var x = 1; { var x = 2; x; }",
+			"2",
+		},
+		{
+			"if branch shadows global",
+			"var x = 1; if (true) { var x = 2; } x;",
+			"1",
+		},
+		{
+			"else branch shadows global",
+			"var x = 1; if (false) { } else { var x = 2; } x;",
+			"1",
+		},
+		{
+			"function shadows global",
+			"var x = 1; func f() { var x = 2; return x; } f();",
+			"2",
+		},
+		{
+			"function shadow leaves global unchanged",
+			"var x = 1; func f() { var x = 2; return x; } f(); x;",
+			"1",
+		},
+		{
+			"parameter shadows global",
+			"var x = 1; func f(x) { return x; } f(5);",
+			"5",
+		},
+		{
+			"nested blocks shadow each other",
+			"var x = 1; { var x = 2; { var x = 3; } x; }",
+			"2",
+		},
+		{
+			"block shadows constant",
+			"const x = 1; { var x = 2; x = 3; } x;",
+			"1",
+		},
+		{
+			"match binding shadows global",
+			"var n = 1; match (5) { n => n }; n;",
+			"1",
+		},
+
+		// redeclaration
+		{
+			"redeclaration in global scope",
+			"var x = 1; var x = 2;",
+			`ERROR: identifier="x" already defined.`,
+		},
+		{
+			"redeclaration in block scope",
+			"{ var x = 1; var x = 2; }",
+			`ERROR: identifier="x" already defined.`,
+		},
+		{
+			"redeclaration of a parameter",
+			"func f(x) { var x = 2; return x; } f(1);",
+			`ERROR: identifier="x" already defined.`,
+		},
+		{
+			"redeclaration of a function",
+			"func f() { return 1; } func f() { return 2; }",
+			`ERROR: identifier="f" already defined.`,
+		},
+		{
+			"variable redeclared as function",
+			"var f = 1; func f() { return 2; }",
+			`ERROR: identifier="f" already defined.`,
+		},
+		{
+			"redeclaration in separate blocks",
+			"{ var x = 1; } { var x = 2; x; }",
+			"2",
+		},
+		{
+			"redeclaration in separate calls",
+			"func f() { var x = 1; return x; } f(); f();",
+			"1",
+		},
+
+		// visibility
+		{
+			"block declarations do not leak",
+			"{ var y = 2; } y;",
+			`ERROR: undefined identifier="y" (y)`,
+		},
+		{
+			"if declarations do not leak",
+			"if (true) { var y = 2; } y;",
+			`ERROR: undefined identifier="y" (y)`,
+		},
+		{
+			"block functions do not leak",
+			"{ func g() { return 1; } } g();",
+			`ERROR: undefined identifier="g" (g)`,
+		},
+		{
+			"inner scope sees outer bindings",
+			"var x = 1; { { x; } }",
+			"1",
+		},
+		{
+			"assignment updates the outer binding",
+			"var x = 1; { x = 2; } x;",
+			"2",
+		},
+		{
+			"assignment updates the nearest binding",
+			"var x = 1; { var x = 2; x = 3; } x;",
+			"1",
+		},
+
+		// closures
+		{
+			"function resolves identifiers where declared",
+			"var x = 1; func f() { return x; } { var x = 2; f(); }",
+			"1",
+		},
+		{
+			"function sees later updates to captured binding",
+			"var x = 1; func f() { return x; } x = 5; f();",
+			"5",
+		},
+		{
+			"inner function captures enclosing call scope",
+			"func outer(a) { func inner() { return a; } return inner; }" +
+				" var g = outer(7); g();",
+			"7",
+		},
+		{
+			"return inside a standalone block",
+			"func f() { { return 1; } return 2; } f();",
+			"1",
+		},
+		{
+			"function sees a later declaration once it is made",
+			"var x = 1; { func f() { return x; } var a = f(); var x = 2;" +
+				" a * 10 + f(); }",
+			"12",
+		},
+		{
+			"mutually recursive functions",
+			"func even(n) { if (n == 0) { return true; } return odd(n - 1); }" +
+				" func odd(n) { if (n == 0) { return false; }" +
+				" return even(n - 1); } even(10);",
+			"true",
+		},
+		{
+			"assignment before a shadowing declaration",
+			"var x = 1; { x = 5; var x = 2; x += 1; } x;",
+			"5",
+		},
+		{
+			"match binding shadows a variable",
+			"var n = 1; var m = match (4) { n => n + 1 }; m * 10 + n;",
+			"51",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := lexer.New(test.input)
+			p := parser.New(l)
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			result := Eval(program, object.NewEnvironment())
+
+			if result.Inspect() != test.expected {
+				t.Errorf("wrong value. got=%s, expected=%s",
+					result.Inspect(), test.expected)
+			}
+		})
+	}
+}
+
+// Scopes with many bindings index them by name.  The statements are evaluated
+// one at a time, as by the REPL, so that every identifier is looked up by name.
+func TestManyDeclarations(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&input, "var v%d = %d;\n", i, i)
+	}
+	input.WriteString(`v3 = v19 + v1;
+		{ var v3 = 100; var v20 = v3 + v1; v20; }
+		v3 + v12;
+		var v7 = 0;`)
+
+	l := lexer.New(input.String())
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	env := object.NewEnvironment()
+	var results []string
+	for _, statement := range program.Statements {
+		results = append(results, Eval(statement, env).Inspect())
+	}
+
+	expected := []string{"20", "101", "32",
+		`ERROR: identifier="v7" already defined.`}
+	for index, value := range expected {
+		if got := results[20+index]; got != value {
+			t.Errorf("tests[%d]: wrong value. got=%s, expected=%s",
+				index, got, value)
+		}
+	}
+}
@@ -1,42 +1,63 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"unicode"
 
 	"github.com/freddiehaddad/corrosion/pkg/token"
 )
 
-// The buffer size of the Lexer's token channel.
-const capacity = 10
-
 // ----------------------------------------------------------------------------
 // Lexer
 // ----------------------------------------------------------------------------
 
-// The Lexer object represents the state of the lexer.
+// The Lexer object represents the state of the lexer.  Tokens are scanned
+// from the input as they are requested with NextToken.
 type Lexer struct {
-	tokens       chan token.Token // tokens generated from stream
-	input        string           // input stream
-	position     int              // the current position in the stream
-	readPosition int              // the next position to read from
-	ch           byte             // the character at position
+	reader io.RuneReader // input stream
+	ch     rune          // the current character
+	next   rune          // the character after ch
+	err    error         // the first error reading the input
+	line   int           // the line of ch, starting at 1
+	column int           // the column of ch, starting at 1
 }
 
-// Creates and returns a Lexer.
+// Creates and returns a Lexer for input.
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
-	l.tokens = make(chan token.Token, capacity)
+	return NewReader(strings.NewReader(input))
+}
+
+// Creates and returns a Lexer reading UTF-8 encoded input from r.  The input
+// is read as tokens are requested, and is buffered unless r implements
+// io.RuneReader.
+func NewReader(r io.Reader) *Lexer {
+	reader, ok := r.(io.RuneReader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+
+	l := &Lexer{reader: reader, line: 1}
+	l.next = l.readRune()
 	l.readCharacter()
-	go l.generateTokens()
 	return l
 }
 
+// Returns the first error, other than io.EOF, encountered reading the input.
+// The lexer treats such errors as the end of input.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 // ----------------------------------------------------------------------------
 // Token generators
 // ----------------------------------------------------------------------------
 
 // Create a new token for single-character terminals.
-func newTokenByte(tokenType token.TokenType, ch byte) token.Token {
+func newTokenRune(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
@@ -51,98 +72,167 @@ func newTokenString(tokenType token.TokenType, literal string) token.Token {
 	}
 }
 
-// Returns the next token from the buffered channel.
+// Scans and returns the next token.  Once the input is exhausted, every call
+// returns the EOF token.
 func (l *Lexer) NextToken() token.Token {
-	tok := <-l.tokens
-	return tok
-}
-
-// Loop through the input stream pushing tokens to the buffered tokens channel.
-func (l *Lexer) generateTokens() {
 	l.consumeWhitespace()
 
-	for l.ch != token.EOF_VALUE {
-		var tok token.Token
-
-		switch l.ch {
-		// delimiters
-		case ';':
-			tok = newTokenByte(token.SEMICOLON, l.ch)
-		case ',':
-			tok = newTokenByte(token.COMMA, l.ch)
-		case '(':
-			tok = newTokenByte(token.LPAREN, l.ch)
-		case ')':
-			tok = newTokenByte(token.RPAREN, l.ch)
-		case '{':
-			tok = newTokenByte(token.LBRACE, l.ch)
-		case '}':
-			tok = newTokenByte(token.RBRACE, l.ch)
-
-		// operators
-		case '-':
-			tok = newTokenByte(token.MINUS, l.ch)
-		case '+':
-			tok = newTokenByte(token.PLUS, l.ch)
-		case '*':
-			tok = newTokenByte(token.MULTIPLY, l.ch)
-		case '/':
-			tok = newTokenByte(token.DIVIDE, l.ch)
-		case '=':
-			if l.peekCharacter() == '=' {
-				l.readCharacter()
-				tok = newTokenString(token.EQ, "==")
-			} else {
-				tok = newTokenByte(token.ASSIGN, l.ch)
-			}
-		case '!':
-			if l.peekCharacter() == '=' {
-				l.readCharacter()
-				tok = newTokenString(token.NOT_EQ, "!=")
-			} else {
-				tok = newTokenByte(token.BANG, l.ch)
-			}
-		case '<':
-			if l.peekCharacter() == '=' {
-				l.readCharacter()
-				tok = newTokenString(token.LT_EQUAL, "<=")
-			} else {
-				tok = newTokenByte(token.LT, l.ch)
+	var tok token.Token
+	line, column := l.line, l.column
+
+	if l.ch == token.EOF_VALUE {
+		tok = newTokenRune(token.EOF, l.ch)
+		tok.Line, tok.Column = line, column
+		return tok
+	}
+
+	switch l.ch {
+	// delimiters
+	case ';':
+		tok = newTokenRune(token.SEMICOLON, l.ch)
+	case ',':
+		tok = newTokenRune(token.COMMA, l.ch)
+	case '?':
+		tok = newTokenRune(token.QUESTION, l.ch)
+	case ':':
+		tok = newTokenRune(token.COLON, l.ch)
+	case '(':
+		tok = newTokenRune(token.LPAREN, l.ch)
+	case ')':
+		tok = newTokenRune(token.RPAREN, l.ch)
+	case '{':
+		tok = newTokenRune(token.LBRACE, l.ch)
+	case '}':
+		tok = newTokenRune(token.RBRACE, l.ch)
+
+	// operators
+	case '-':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.MINUS_ASSIGN, "-=")
+		} else if l.peekCharacter() == '-' {
+			l.readCharacter()
+			tok = newTokenString(token.DECREMENT, "--")
+		} else {
+			tok = newTokenRune(token.MINUS, l.ch)
+		}
+	case '+':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.PLUS_ASSIGN, "+=")
+		} else if l.peekCharacter() == '+' {
+			l.readCharacter()
+			tok = newTokenString(token.INCREMENT, "++")
+		} else {
+			tok = newTokenRune(token.PLUS, l.ch)
+		}
+	case '*':
+		if l.peekCharacter() == '*' {
+			l.readCharacter()
+			tok = newTokenString(token.EXPONENT, "**")
+		} else if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.MULTIPLY_ASSIGN, "*=")
+		} else {
+			tok = newTokenRune(token.MULTIPLY, l.ch)
+		}
+	case '/':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.DIVIDE_ASSIGN, "/=")
+		} else {
+			tok = newTokenRune(token.DIVIDE, l.ch)
+		}
+	case '%':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.MODULO_ASSIGN, "%=")
+		} else {
+			tok = newTokenRune(token.MODULO, l.ch)
+		}
+	case '&':
+		if l.peekCharacter() == '&' {
+			l.readCharacter()
+			tok = newTokenString(token.AND, "&&")
+		} else {
+			tok = newTokenRune(token.BITWISE_AND, l.ch)
+		}
+	case '|':
+		if l.peekCharacter() == '|' {
+			l.readCharacter()
+			tok = newTokenString(token.OR, "||")
+		} else {
+			tok = newTokenRune(token.BITWISE_OR, l.ch)
+		}
+	case '^':
+		tok = newTokenRune(token.BITWISE_XOR, l.ch)
+	case '~':
+		tok = newTokenRune(token.BITWISE_NOT, l.ch)
+	case '=':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.EQ, "==")
+		} else if l.peekCharacter() == '>' {
+			l.readCharacter()
+			tok = newTokenString(token.ARROW, "=>")
+		} else {
+			tok = newTokenRune(token.ASSIGN, l.ch)
+		}
+	case '!':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.NOT_EQ, "!=")
+		} else {
+			tok = newTokenRune(token.BANG, l.ch)
+		}
+	case '<':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.LT_EQUAL, "<=")
+		} else if l.peekCharacter() == '<' {
+			l.readCharacter()
+			tok = newTokenString(token.SHIFT_LEFT, "<<")
+		} else {
+			tok = newTokenRune(token.LT, l.ch)
+		}
+	case '>':
+		if l.peekCharacter() == '=' {
+			l.readCharacter()
+			tok = newTokenString(token.GT_EQUAL, ">=")
+		} else if l.peekCharacter() == '>' {
+			l.readCharacter()
+			tok = newTokenString(token.SHIFT_RIGHT, ">>")
+		} else {
+			tok = newTokenRune(token.GT, l.ch)
+		}
+
+	default:
+		// identifiers, of which a lone underscore is the wildcard
+		if isIdentifierStart(l.ch) {
+			s := l.readWord()
+			tt := token.LookupType(s)
+			if s == token.WILDCARD {
+				tt = token.WILDCARD
 			}
-		case '>':
-			if l.peekCharacter() == '=' {
-				l.readCharacter()
-				tok = newTokenString(token.GT_EQUAL, ">=")
+			tok = newTokenString(tt, s)
+			// integer literals
+		} else if isDigit(l.ch) {
+			s := l.readNumber()
+			if NumberError(s) != nil {
+				tok = newTokenString(token.ILLEGAL, s)
 			} else {
-				tok = newTokenByte(token.GT, l.ch)
-			}
-
-		default:
-			// identifiers
-			if isAlpha(l.ch) {
-				s := l.readWord()
-				tt := token.LookupType(s)
-				tok = newTokenString(tt, s)
-				// integer literals
-			} else if isDigit(l.ch) {
-				s := l.readNumber()
 				tok = newTokenString(token.INTEGER, s)
-				// invalid tokens
-			} else {
-				tok = newTokenByte(token.ILLEGAL, l.ch)
 			}
+			// invalid tokens
+		} else {
+			tok = newTokenRune(token.ILLEGAL, l.ch)
 		}
-
-		l.tokens <- tok
-
-		l.readCharacter()
-		l.consumeWhitespace()
 	}
 
-	// end of input
-	tok := newTokenByte(token.EOF, l.ch)
-	l.tokens <- tok
-	close(l.tokens)
+	tok.Line, tok.Column = line, column
+	l.readCharacter()
+
+	return tok
 }
 
 // ----------------------------------------------------------------------------
@@ -150,17 +240,23 @@ func (l *Lexer) generateTokens() {
 // ----------------------------------------------------------------------------
 
 // Returns true if ch is a numeric value.
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
-// Returns true if ch is an alpha value.
-func isAlpha(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+// Returns true if ch can start an identifier: a letter or an underscore.
+func isIdentifierStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
+// Returns true if ch can continue an identifier: a letter, a digit or an
+// underscore.
+func isIdentifierPart(ch rune) bool {
+	return isIdentifierStart(ch) || unicode.IsDigit(ch)
 }
 
 // Returns true if ch is a whitespace value.
-func isWhitespace(ch byte) bool {
+func isWhitespace(ch rune) bool {
 	switch ch {
 	case ' ', '\r', '\n', '\t':
 		return true
@@ -179,59 +275,143 @@ func (l *Lexer) consumeWhitespace() {
 // Advances the lexer position one character.  If the lexer has reached the end
 // of the stream, no change to the state occurs.
 func (l *Lexer) readCharacter() {
-	if l.eof() {
-		l.ch = token.EOF_VALUE
-		return
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else if l.ch != token.EOF_VALUE || l.column == 0 {
+		l.column++
 	}
 
-	l.ch = l.input[l.readPosition]
-	l.position = l.readPosition
-	l.readPosition++
+	l.ch = l.next
+	if l.next != token.EOF_VALUE {
+		l.next = l.readRune()
+	}
 }
 
 // Returns the next character in the sequence without advancing.  Returns
 // the end of file value if the stream has reached the end.
-func (l *Lexer) peekCharacter() byte {
-	if l.eof() {
-		return token.EOF_VALUE
-	}
-
-	return l.input[l.readPosition]
+func (l *Lexer) peekCharacter() rune {
+	return l.next
 }
 
-// Returns true if the lexer has reached the end of input.
-func (l *Lexer) eof() bool {
-	return l.readPosition == len(l.input)
+// Decodes the next UTF-8 encoded rune from the input.  Invalid encodings are
+// read one byte at a time as utf8.RuneError.  Returns the end of file value at
+// the end of the input or if reading fails.
+func (l *Lexer) readRune() rune {
+	ch, _, err := l.reader.ReadRune()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			l.err = err
+		}
+		return token.EOF_VALUE
+	}
+	return ch
 }
 
-// Generates a string from a consecutive sequence of characters where isAlpha
-// returns true starting with the current character (l.ch) and continuing until
-// the peekCharacter does not meet the isAlpha condition. When returning, l.ch
-// will point to the last consumed character.
+// Generates a string from a consecutive sequence of characters starting with
+// the current character (l.ch) and continuing until the peekCharacter does not
+// meet the isIdentifierPart condition. When returning, l.ch will point to the
+// last consumed character.
 func (l *Lexer) readWord() string {
 	sb := strings.Builder{}
 
-	sb.WriteByte(l.ch)
-	for isAlpha(l.peekCharacter()) {
+	sb.WriteRune(l.ch)
+	for isIdentifierPart(l.peekCharacter()) {
 		l.readCharacter()
-		sb.WriteByte(l.ch)
+		sb.WriteRune(l.ch)
 	}
 
 	return sb.String()
 }
 
-// Generates a string from a consecutive sequence of characters where isDigit
-// returns true starting with the current character (l.ch) and continuing until
-// the peekCharacter does not meet the isDigit condition. When returning, l.ch
-// will point to the last consumed character.
+// Generates a string from a consecutive sequence of characters starting with
+// the current digit (l.ch) and continuing until the peekCharacter does not meet
+// the isIdentifierPart condition.  Letters are consumed so that a malformed
+// number such as 12abc is reported as one token rather than two.  When
+// returning, l.ch will point to the last consumed character.
 func (l *Lexer) readNumber() string {
 	sb := strings.Builder{}
 
-	sb.WriteByte(l.ch)
-	for isDigit(l.peekCharacter()) {
+	sb.WriteRune(l.ch)
+	for isIdentifierPart(l.peekCharacter()) {
 		l.readCharacter()
-		sb.WriteByte(l.ch)
+		sb.WriteRune(l.ch)
 	}
 
 	return sb.String()
 }
+
+// ----------------------------------------------------------------------------
+// Integer literals
+// ----------------------------------------------------------------------------
+
+// Names of the number bases, used in diagnostics.
+var baseNames = map[int]string{
+	2:  "binary",
+	8:  "octal",
+	10: "decimal",
+	16: "hexadecimal",
+}
+
+// NumberError checks that literal is a well formed integer literal and returns
+// an error describing the problem if it is not.  Literals are decimal, or
+// binary, octal or hexadecimal with a 0b, 0o or 0x prefix.  A leading 0 also
+// denotes octal.  Underscores may separate digits, and follow a prefix, to make
+// long literals readable: 1_000_000 or 0x_FF_FF.
+func NumberError(literal string) error {
+	if literal == "" {
+		return errors.New("empty literal")
+	}
+
+	base, digits := 10, literal
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base, digits = 16, literal[2:]
+		case 'o', 'O':
+			base, digits = 8, literal[2:]
+		case 'b', 'B':
+			base, digits = 2, literal[2:]
+		default:
+			base, digits = 8, literal[1:]
+		}
+
+		if digits == "" {
+			return fmt.Errorf("%s literal has no digits", baseNames[base])
+		}
+	}
+
+	// an underscore must come between two digits, or between a prefix and
+	// the first digit
+	underscore := base != 10
+	for i, ch := range digits {
+		if ch == '_' {
+			if !underscore || i == len(digits)-1 {
+				return errors.New("'_' must separate successive digits")
+			}
+			underscore = false
+			continue
+		}
+
+		if digitValue(ch) >= base {
+			return fmt.Errorf("invalid digit %q in %s literal",
+				ch, baseNames[base])
+		}
+		underscore = true
+	}
+
+	return nil
+}
+
+// Returns the value of the digit ch, or 16 if ch is not a hexadecimal digit.
+func digitValue(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
+	}
+	return 16
+}
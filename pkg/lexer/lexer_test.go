@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/freddiehaddad/corrosion/pkg/token"
 )
@@ -31,14 +36,16 @@ func compareTokens(t *testing.T, l *Lexer, tokens []expectedToken) {
 
 func TestNextToken(t *testing.T) {
 	input := `
-	var return func if else x true false !!= <<= >>= +-*/= 10;==)({},$
+	var const return func if else match x true false !!= < <= > >= + - * / = 10;==)({},?:=>_$
 	`
 	tests := []expectedToken{
 		{expectedType: token.VAR, expectedLiteral: "var"},
+		{expectedType: token.CONST, expectedLiteral: "const"},
 		{expectedType: token.RETURN, expectedLiteral: "return"},
 		{expectedType: token.FUNC, expectedLiteral: "func"},
 		{expectedType: token.IF, expectedLiteral: "if"},
 		{expectedType: token.ELSE, expectedLiteral: "else"},
+		{expectedType: token.MATCH, expectedLiteral: "match"},
 		{expectedType: token.IDENT, expectedLiteral: "x"},
 		{expectedType: token.TRUE, expectedLiteral: "true"},
 		{expectedType: token.FALSE, expectedLiteral: "false"},
@@ -61,6 +68,10 @@ func TestNextToken(t *testing.T) {
 		{expectedType: token.LBRACE, expectedLiteral: "{"},
 		{expectedType: token.RBRACE, expectedLiteral: "}"},
 		{expectedType: token.COMMA, expectedLiteral: ","},
+		{expectedType: token.QUESTION, expectedLiteral: "?"},
+		{expectedType: token.COLON, expectedLiteral: ":"},
+		{expectedType: token.ARROW, expectedLiteral: "=>"},
+		{expectedType: token.WILDCARD, expectedLiteral: "_"},
 		{expectedType: token.ILLEGAL, expectedLiteral: "$"},
 		{
 			expectedType:    token.EOF,
@@ -71,3 +82,355 @@ func TestNextToken(t *testing.T) {
 	l := New(input)
 	compareTokens(t, l, tests)
 }
+
+func TestNextTokenArithmeticAndBitwise(t *testing.T) {
+	input := "% ** * & | ^ ~ << >> <<= >>="
+	tests := []expectedToken{
+		{expectedType: token.MODULO, expectedLiteral: "%"},
+		{expectedType: token.EXPONENT, expectedLiteral: "**"},
+		{expectedType: token.MULTIPLY, expectedLiteral: "*"},
+		{expectedType: token.BITWISE_AND, expectedLiteral: "&"},
+		{expectedType: token.BITWISE_OR, expectedLiteral: "|"},
+		{expectedType: token.BITWISE_XOR, expectedLiteral: "^"},
+		{expectedType: token.BITWISE_NOT, expectedLiteral: "~"},
+		{expectedType: token.SHIFT_LEFT, expectedLiteral: "<<"},
+		{expectedType: token.SHIFT_RIGHT, expectedLiteral: ">>"},
+		{expectedType: token.SHIFT_LEFT, expectedLiteral: "<<"},
+		{expectedType: token.ASSIGN, expectedLiteral: "="},
+		{expectedType: token.SHIFT_RIGHT, expectedLiteral: ">>"},
+		{expectedType: token.ASSIGN, expectedLiteral: "="},
+		{
+			expectedType:    token.EOF,
+			expectedLiteral: string(token.EOF_VALUE),
+		},
+	}
+
+	l := New(input)
+	compareTokens(t, l, tests)
+}
+
+func TestNextTokenLogical(t *testing.T) {
+	input := "&& || & | &&& |||"
+	tests := []expectedToken{
+		{expectedType: token.AND, expectedLiteral: "&&"},
+		{expectedType: token.OR, expectedLiteral: "||"},
+		{expectedType: token.BITWISE_AND, expectedLiteral: "&"},
+		{expectedType: token.BITWISE_OR, expectedLiteral: "|"},
+		{expectedType: token.AND, expectedLiteral: "&&"},
+		{expectedType: token.BITWISE_AND, expectedLiteral: "&"},
+		{expectedType: token.OR, expectedLiteral: "||"},
+		{expectedType: token.BITWISE_OR, expectedLiteral: "|"},
+		{
+			expectedType:    token.EOF,
+			expectedLiteral: string(token.EOF_VALUE),
+		},
+	}
+
+	l := New(input)
+	compareTokens(t, l, tests)
+}
+
+func TestNextTokenAssignmentOperators(t *testing.T) {
+	input := "+= -= *= /= %= ++ -- **= +++ - -"
+	tests := []expectedToken{
+		{expectedType: token.PLUS_ASSIGN, expectedLiteral: "+="},
+		{expectedType: token.MINUS_ASSIGN, expectedLiteral: "-="},
+		{expectedType: token.MULTIPLY_ASSIGN, expectedLiteral: "*="},
+		{expectedType: token.DIVIDE_ASSIGN, expectedLiteral: "/="},
+		{expectedType: token.MODULO_ASSIGN, expectedLiteral: "%="},
+		{expectedType: token.INCREMENT, expectedLiteral: "++"},
+		{expectedType: token.DECREMENT, expectedLiteral: "--"},
+		{expectedType: token.EXPONENT, expectedLiteral: "**"},
+		{expectedType: token.ASSIGN, expectedLiteral: "="},
+		{expectedType: token.INCREMENT, expectedLiteral: "++"},
+		{expectedType: token.PLUS, expectedLiteral: "+"},
+		{expectedType: token.MINUS, expectedLiteral: "-"},
+		{expectedType: token.MINUS, expectedLiteral: "-"},
+		{
+			expectedType:    token.EOF,
+			expectedLiteral: string(token.EOF_VALUE),
+		},
+	}
+
+	l := New(input)
+	compareTokens(t, l, tests)
+}
+
+func TestNextTokenAfterEOF(t *testing.T) {
+	l := New("x")
+
+	tests := []expectedToken{
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.EOF, expectedLiteral: "\x00"},
+		{expectedType: token.EOF, expectedLiteral: "\x00"},
+		{expectedType: token.EOF, expectedLiteral: "\x00"},
+	}
+
+	compareTokens(t, l, tests)
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "var x = 10;\nfunc f(a) {\n\treturn a;\n}"
+
+	tests := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"var", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"10", 1, 9},
+		{";", 1, 11},
+		{"func", 2, 1},
+		{"f", 2, 6},
+		{"(", 2, 7},
+		{"a", 2, 8},
+		{")", 2, 9},
+		{"{", 2, 11},
+		{"return", 3, 2},
+		{"a", 3, 9},
+		{";", 3, 10},
+		{"}", 4, 1},
+		{"\x00", 4, 2},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal || tok.Line != tt.line ||
+			tok.Column != tt.column {
+			t.Errorf("tests[%d] - expected %q at %d:%d got=%q at %d:%d",
+				i, tt.literal, tt.line, tt.column,
+				tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestNextTokenIdentifiers(t *testing.T) {
+	input := "user_id x1 _private __ _ a_1_b größe 名前 αβγ 1x € \xff"
+
+	tests := []expectedToken{
+		{expectedType: token.IDENT, expectedLiteral: "user_id"},
+		{expectedType: token.IDENT, expectedLiteral: "x1"},
+		{expectedType: token.IDENT, expectedLiteral: "_private"},
+		{expectedType: token.IDENT, expectedLiteral: "__"},
+		{expectedType: token.WILDCARD, expectedLiteral: "_"},
+		{expectedType: token.IDENT, expectedLiteral: "a_1_b"},
+		{expectedType: token.IDENT, expectedLiteral: "größe"},
+		{expectedType: token.IDENT, expectedLiteral: "名前"},
+		{expectedType: token.IDENT, expectedLiteral: "αβγ"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "1x"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "€"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "�"},
+		{expectedType: token.EOF, expectedLiteral: string(token.EOF_VALUE)},
+	}
+
+	l := New(input)
+	compareTokens(t, l, tests)
+}
+
+func TestNextTokenUnicodePositions(t *testing.T) {
+	input := "var größe = 1;\nπ;"
+
+	tests := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"var", 1, 1},
+		{"größe", 1, 5},
+		{"=", 1, 11},
+		{"1", 1, 13},
+		{";", 1, 14},
+		{"π", 2, 1},
+		{";", 2, 2},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal || tok.Line != tt.line ||
+			tok.Column != tt.column {
+			t.Errorf("tests[%d] - expected %q at %d:%d got=%q at %d:%d",
+				i, tt.literal, tt.line, tt.column,
+				tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestNextTokenNumbers(t *testing.T) {
+	input := "0xFF 0o17 0b1010 1_000_000 0x_ab 017 0x 12abc 1_ 0b2 09"
+
+	tests := []expectedToken{
+		{expectedType: token.INTEGER, expectedLiteral: "0xFF"},
+		{expectedType: token.INTEGER, expectedLiteral: "0o17"},
+		{expectedType: token.INTEGER, expectedLiteral: "0b1010"},
+		{expectedType: token.INTEGER, expectedLiteral: "1_000_000"},
+		{expectedType: token.INTEGER, expectedLiteral: "0x_ab"},
+		{expectedType: token.INTEGER, expectedLiteral: "017"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "0x"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "12abc"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "1_"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "0b2"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "09"},
+		{expectedType: token.EOF, expectedLiteral: string(token.EOF_VALUE)},
+	}
+
+	l := New(input)
+	compareTokens(t, l, tests)
+}
+
+func TestNumberError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0", ""},
+		{"123", ""},
+		{"1_2_3", ""},
+		{"0xdead_BEEF", ""},
+		{"0B_1", ""},
+		{"0O7_7", ""},
+		{"0_7", ""},
+		{"", "empty literal"},
+		{"0x", "hexadecimal literal has no digits"},
+		{"0o", "octal literal has no digits"},
+		{"0b", "binary literal has no digits"},
+		{"0xg", "invalid digit 'g' in hexadecimal literal"},
+		{"0o8", "invalid digit '8' in octal literal"},
+		{"0b12", "invalid digit '2' in binary literal"},
+		{"089", "invalid digit '8' in octal literal"},
+		{"12abc", "invalid digit 'a' in decimal literal"},
+		{"1__0", "'_' must separate successive digits"},
+		{"1_", "'_' must separate successive digits"},
+		{"0x_", "'_' must separate successive digits"},
+		{"0x__1", "'_' must separate successive digits"},
+	}
+
+	for index, test := range tests {
+		err := NumberError(test.input)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.expected {
+			t.Errorf("tests[%d]: NumberError(%q) expected %q got=%q",
+				index, test.input, test.expected, got)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "var größe = 0x_FF;\nfunc f(a) { return a * größe; }"
+
+	// the reader returns one byte at a time, splitting multi-byte runes
+	expected := New(input)
+	l := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	for i := 0; ; i++ {
+		want, got := expected.NextToken(), l.NextToken()
+		if got != want {
+			t.Fatalf("tests[%d] - expected %+v got=%+v", i, want, got)
+		}
+		if got.Type == token.EOF {
+			break
+		}
+	}
+
+	if err := l.Err(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	failure := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("var x"), iotest.ErrReader(failure))
+
+	tests := []expectedToken{
+		{expectedType: token.VAR, expectedLiteral: "var"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.EOF, expectedLiteral: string(token.EOF_VALUE)},
+	}
+
+	l := NewReader(r)
+	compareTokens(t, l, tests)
+
+	if err := l.Err(); !errors.Is(err, failure) {
+		t.Errorf("expected error %v got=%v", failure, err)
+	}
+}
+
+func TestNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// lexers abandoned before the end of input must not leave anything
+	// running
+	for i := 0; i < 100; i++ {
+		l := New("var x = 1; var y = 2; var z = 3;")
+		l.NextToken()
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected %d goroutines got=%d", before, after)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Benchmarks
+// ----------------------------------------------------------------------------
+
+// About 60KB of source to measure throughput.
+var benchmarkInput = strings.Repeat(`func fib(n) {
+	if (n < 2) { return n; }
+	return fib(n - 1) + fib(n - 2);
+}
+var total_count = 0x_FF + 1_000;
+total_count += fib(10) * 2;
+`, 500)
+
+// Reads every token from l.
+func drain(l *Lexer) {
+	for l.NextToken().Type != token.EOF {
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		drain(New(benchmarkInput))
+	}
+}
+
+func BenchmarkNextTokenReader(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		// hide the io.RuneReader method so the input is buffered
+		r := struct{ io.Reader }{strings.NewReader(benchmarkInput)}
+		drain(NewReader(r))
+	}
+}
+
+// The lexer as it was previously designed, scanning on a goroutine and
+// sending the tokens over a channel with ten slots.
+func BenchmarkNextTokenChannel(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		l := New(benchmarkInput)
+		tokens := make(chan token.Token, 10)
+		go func() {
+			defer close(tokens)
+			for {
+				tok := l.NextToken()
+				tokens <- tok
+				if tok.Type == token.EOF {
+					return
+				}
+			}
+		}()
+
+		for range tokens {
+		}
+	}
+}
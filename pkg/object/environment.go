@@ -4,40 +4,141 @@ package object
 
 import (
 	"fmt"
+	"sort"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
 )
 
 // Environment represents the state of the environment, both globally and
 // scoped environments (i.e. within scoped blocks and function calls).
+//
+// Scoping is lexical.  The program runs in the global environment; every
+// block statement (standalone, if and else branches) and every function call
+// creates a scoped environment whose outer environment is the enclosing scope
+// (for calls, the scope in which the function was declared).  A declaration
+// may shadow a binding from an outer scope but may not redeclare a name
+// already bound in the same scope.
+//
+// The bindings of a scope are kept in the order they were declared.  The
+// evaluator resolves identifiers to a position in the chain of scopes (see
+// GetSlot), so most lookups index a slice instead of searching for a name.
+// Names that were not resolved are searched for, through a map from names to
+// positions once a scope has more than indexedBindings bindings.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	bindings  []binding
+	names     map[string]int
+	constants map[string]bool
+	outer     *Environment
+	hook      Hook
+	function  bool
+}
+
+type binding struct {
+	name  string
+	value Object
+}
+
+// The number of bindings above which a scope indexes them by name.  Most
+// scopes, such as those of function calls, hold a few bindings, which are
+// found sooner by comparing names than by building and consulting a map.
+const indexedBindings = 8
+
+// Hook receives notifications from the evaluator, e.g. for a debugger.  A
+// hook set on an environment is inherited by the scoped environments created
+// from it and by the calls made in it, whatever environment the called
+// function was declared in.
+type Hook interface {
+	// Called before each statement in a program or block is evaluated.
+	Statement(node ast.Statement, env *Environment)
+
+	// Called when a call to fn is entered, with the environment holding its
+	// parameters, and when it returns result.  A call that ends in a tail
+	// call is exited, with a nil result, before the tail call is entered.
+	EnterCall(node *ast.FunctionCallExpression, fn *Function, env *Environment)
+	ExitCall(node *ast.FunctionCallExpression, result Object)
 }
 
 // NewEnvironment creates the top level (global) environment.  Additional
 // environments for scoping (i.e. block statements, functions) should use
 // NewScopedEnvironment.
 func NewEnvironment() *Environment {
-	store := make(map[string]Object)
-	return &Environment{store: store}
+	return &Environment{}
 }
 
 // Creates a scoped environment that is part of function calls and block
 // statements.
 func NewScopedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{outer: outer, hook: outer.hook, function: outer.function}
+}
+
+// Creates the scoped environment holding the parameters of a function call.
+// The environment has no hook: a call is notified to the hook of its caller,
+// not that of the scope the function was declared in.
+func NewFunctionEnvironment(outer *Environment) *Environment {
+	return &Environment{outer: outer, function: true}
+}
+
+// Reports whether e is the environment of a function call or of a scope
+// within one, as opposed to the global environment or a block outside of any
+// function.
+func (e *Environment) InFunction() bool {
+	return e.function
 }
 
 // Checks the current environment (including outer scopes) for the identifier
 // name and returns its value if found along with the value true.  Otherwise,
 // obj is undefined and ok will be false. Always check the result of ok before
 func (e *Environment) Get(name string) (obj Object, ok bool) {
-	obj, ok = e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	return e.GetSlot(name, -1, 0)
+}
+
+// GetSlot is like Get, but first looks for name at position index in the
+// scope depth scopes out from e, where the evaluator's resolver expects it.
+// If name is not bound there, as when the scope has yet to declare it or the
+// position was resolved for another program, it is looked up as by Get.  A
+// negative depth skips straight to the lookup.
+func (e *Environment) GetSlot(name string, depth, index int) (Object, bool) {
+	if env, i := e.find(name, depth, index); env != nil {
+		return env.bindings[i].value, true
+	}
+	return nil, false
+}
+
+// Checks only the current environment, ignoring outer scopes, for the
+// identifier name and returns its value if found along with the value true.
+// Used to detect redeclarations, which are only errors within a single scope.
+func (e *Environment) GetLocal(name string) (obj Object, ok bool) {
+	if i := e.index(name); i >= 0 {
+		return e.bindings[i].value, true
+	}
+	return nil, false
+}
+
+// Returns the names bound in the current environment, ignoring outer scopes,
+// in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.bindings))
+	for _, b := range e.bindings {
+		names = append(names, b.name)
 	}
-	return
+	sort.Strings(names)
+	return names
+}
+
+// Sets the hook notified while evaluating in the environment.  A nil hook
+// removes it.
+func (e *Environment) SetHook(hook Hook) {
+	e.hook = hook
+}
+
+// Returns the hook for the environment, or nil if there is none.
+func (e *Environment) Hook() Hook {
+	return e.hook
+}
+
+// Returns the enclosing environment, or nil for the global environment.
+func (e *Environment) Outer() *Environment {
+	return e.outer
 }
 
 // Sets the mapping of name to value in the current environment. Returns value.
@@ -49,24 +150,107 @@ func (e *Environment) Get(name string) (obj Object, ok bool) {
 //	var foo = 100;  Handled by Set
 //	foo = 200;      Should be handled with Update
 func (e *Environment) Set(name string, value Object) Object {
-	e.store[name] = value
+	if i := e.index(name); i >= 0 {
+		e.bindings[i].value = value
+		return value
+	}
+
+	e.bindings = append(e.bindings, binding{name: name, value: value})
+	switch {
+	case e.names != nil:
+		e.names[name] = len(e.bindings) - 1
+	case len(e.bindings) > indexedBindings:
+		e.names = make(map[string]int, 2*len(e.bindings))
+		for i, b := range e.bindings {
+			e.names[b.name] = i
+		}
+	}
 	return value
 }
 
+// SetConstant is like Set, but the binding is immutable and subsequent calls to
+// Update for name will fail.
+//
+// Example:
+//
+//	const foo = 100;  Handled by SetConstant
+func (e *Environment) SetConstant(name string, value Object) Object {
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+	return e.Set(name, value)
+}
+
+// Reports whether name is bound as a constant in the current environment,
+// ignoring outer scopes.
+func (e *Environment) IsConstant(name string) bool {
+	return e.constants[name]
+}
+
 // Update assigns the value to the existing identifier name and returns value
 // and true.  If name does not exist (meaning it hasn't already been declared),
-// then value is returned and false.
+// or was declared as a constant, an error object is returned and false.
 func (e *Environment) Update(name string, value Object) (Object, bool) {
-	if _, ok := e.store[name]; ok {
-		e.store[name] = value
-		return value, ok
+	return e.UpdateSlot(name, -1, 0, value)
+}
+
+// UpdateSlot is like Update, but first looks for name where GetSlot would.
+func (e *Environment) UpdateSlot(
+	name string, depth, index int, value Object,
+) (Object, bool) {
+	env, i := e.find(name, depth, index)
+	if env == nil {
+		m := fmt.Sprintf("ERROR: undefined variable %q", name)
+		return &Error{Value: m}, false
+	}
+
+	if env.constants[name] {
+		m := fmt.Sprintf("ERROR: cannot assign to constant %q", name)
+		return &Error{Value: m}, false
+	}
+
+	env.bindings[i].value = value
+	return value, true
+}
+
+// Returns the environment binding name and the position of the binding in
+// it, trying the slot given by depth and index before searching the scopes
+// from e outwards.  Returns nil if name is not bound.
+func (e *Environment) find(name string, depth, index int) (*Environment, int) {
+	if depth >= 0 {
+		env := e
+		for ; env != nil && depth > 0; depth-- {
+			env = env.outer
+		}
+		if env != nil && index < len(env.bindings) &&
+			env.bindings[index].name == name {
+			return env, index
+		}
+	}
+
+	for env := e; env != nil; env = env.outer {
+		if i := env.index(name); i >= 0 {
+			return env, i
+		}
 	}
+	return nil, -1
+}
 
-	if e.outer != nil {
-		return e.outer.Update(name, value)
+// Returns the position of the binding of name in the current environment, or
+// -1 if there is none.
+func (e *Environment) index(name string) int {
+	if e.names != nil {
+		if i, ok := e.names[name]; ok {
+			return i
+		}
+		return -1
 	}
 
-	m := fmt.Sprintf("ERROR: undefined variable %q", name)
-	err := &Error{Value: m}
-	return err, false
+	for i := range e.bindings {
+		if e.bindings[i].name == name {
+			return i
+		}
+	}
+	return -1
 }
@@ -2,6 +2,7 @@ package object
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/freddiehaddad/corrosion/pkg/ast"
@@ -16,9 +17,11 @@ type Object interface {
 
 const (
 	INTEGER_OBJ  = "INTEGER"
+	BIGINT_OBJ   = "BIGINT"
 	BOOLEAN_OBJ  = "BOOLEAN"
 	FUNCTION_OBJ = "FUNCTION"
 	RETURN_OBJ   = "RETURN"
+	TAILCALL_OBJ = "TAILCALL"
 	ERROR_OBJ    = "ERROR"
 	NULL_OBJ     = "NULL"
 )
@@ -29,6 +32,7 @@ const (
 
 // Function
 type Function struct {
+	Name       string // name given to the function by its declaration
 	Body       ast.Statement
 	Env        *Environment
 	Parameters []ast.Identifier
@@ -63,6 +67,17 @@ type Return struct {
 func (r *Return) Type() ObjectType { return RETURN_OBJ }
 func (r *Return) Inspect() string  { return r.Value.Inspect() }
 
+// A call to Function made by a return statement, returned as the Value of its
+// Return so that the call being returned from can make it in its place.
+type TailCall struct {
+	Node      *ast.FunctionCallExpression
+	Function  *Function
+	Arguments []Object
+}
+
+func (tc *TailCall) Type() ObjectType { return TAILCALL_OBJ }
+func (tc *TailCall) Inspect() string  { return tc.Node.String() }
+
 // ----------------------------------------------------------------------------
 // Primitive types
 // ----------------------------------------------------------------------------
@@ -81,6 +96,15 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// Arbitrary-precision integer produced when a literal or arithmetic result
+// does not fit in an Integer.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+
 // ----------------------------------------------------------------------------
 // Evaluator generated types
 // ----------------------------------------------------------------------------
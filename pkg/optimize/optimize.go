@@ -0,0 +1,209 @@
+// The optimize package simplifies Corrosion programs before they are
+// evaluated.
+//
+// Program folds integer and boolean arithmetic, logic and comparisons whose
+// operands are literals into a single literal, removes branches of if
+// statements and conditional expressions that can never be taken, and
+// removes statements that follow a return in the same block.
+//
+// Constant expressions are folded by evaluating them with the evaluator, so
+// the results are exactly those the program would compute.  An expression
+// whose evaluation fails, such as a division by zero, is left in place so the
+// error is still reported when, and only if, the program reaches it.
+package optimize
+
+import (
+	"strconv"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/token"
+)
+
+// Program optimizes program in place and returns it.
+func Program(program *ast.Program) *ast.Program {
+	ast.Rewrite(program, optimize)
+	return program
+}
+
+// Returns the replacement for node, whose children have already been
+// optimized.
+func optimize(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.InfixExpression:
+		if isLiteral(n.Left) && (isLiteral(n.Right) || shortCircuits(n)) {
+			return fold(n, n.Token)
+		}
+	case *ast.PrefixExpression:
+		if isLiteral(n.Right) {
+			return fold(n, n.Token)
+		}
+	case *ast.ConditionalExpression:
+		if condition, ok := n.Condition.(*ast.Boolean); ok {
+			if condition.Value {
+				return n.Consequence
+			}
+			return n.Alternative
+		}
+	case *ast.IfStatement:
+		simplifyIf(n)
+	case *ast.BlockStatement:
+		n.Statements = simplifyStatements(n.Statements, true)
+	case *ast.Program:
+		// a return does not end a program, so statements after it remain
+		n.Statements = simplifyStatements(n.Statements, false)
+	}
+
+	return node
+}
+
+// ----------------------------------------------------------------------------
+// Constant folding
+// ----------------------------------------------------------------------------
+
+// Reports whether e is an integer or boolean literal.
+func isLiteral(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// Reports whether the left operand of a && or || expression determines its
+// result, so the right operand is never evaluated.
+func shortCircuits(ie *ast.InfixExpression) bool {
+	left, ok := ie.Left.(*ast.Boolean)
+	if !ok {
+		return false
+	}
+
+	switch ie.Operator {
+	case "&&":
+		return !left.Value
+	case "||":
+		return left.Value
+	}
+	return false
+}
+
+// Evaluates e, whose value depends only on literals, and returns a literal
+// holding the result at the position of t.  If the evaluation fails, e is
+// returned unchanged so the error occurs when the program runs.
+func fold(e ast.Expression, t token.Token) ast.Expression {
+	switch value := evaluator.Eval(e, object.NewEnvironment()).(type) {
+	case *object.Integer:
+		literal := strconv.FormatInt(value.Value, 10)
+		return &ast.IntegerLiteral{
+			Token: newToken(t, token.INTEGER, literal),
+			Value: value.Value,
+		}
+	case *object.BigInt:
+		return &ast.BigIntegerLiteral{
+			Token: newToken(t, token.INTEGER, value.Value.String()),
+			Value: value.Value,
+		}
+	case *object.Boolean:
+		if value.Value {
+			return &ast.Boolean{
+				Token: newToken(t, token.TRUE, "true"),
+				Value: true,
+			}
+		}
+		return &ast.Boolean{
+			Token: newToken(t, token.FALSE, "false"),
+			Value: false,
+		}
+	}
+
+	return e
+}
+
+// Returns a token of type tt with literal at the position of t.
+func newToken(t token.Token, tt token.TokenType, literal string) token.Token {
+	return token.Token{
+		Type:    tt,
+		Literal: literal,
+		Line:    t.Line,
+		Column:  t.Column,
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Unreachable code
+// ----------------------------------------------------------------------------
+
+// Removes the branch of an if statement with a constant condition that can
+// never be taken.  A statement whose condition is false is rewritten to take
+// its alternative unconditionally, since an if statement may be used as an
+// expression and so cannot always be replaced by a block.
+func simplifyIf(is *ast.IfStatement) {
+	condition, ok := is.Condition.(*ast.Boolean)
+	if !ok {
+		return
+	}
+
+	if !condition.Value && is.Alternative != nil {
+		is.Condition = &ast.Boolean{
+			Token: newToken(condition.Token, token.TRUE, "true"),
+			Value: true,
+		}
+		is.Consequence = is.Alternative
+	}
+
+	if is.Condition.(*ast.Boolean).Value {
+		is.Alternative = nil
+	}
+}
+
+// Simplifies a list of statements.  If statements with a constant condition
+// are replaced by the branch that is taken, or removed if no branch is taken
+// and they are not the last statement, whose value is the value of the list.
+// When block is true, the statements following one that always returns are
+// removed.
+func simplifyStatements(statements []ast.Statement,
+	block bool,
+) []ast.Statement {
+	result := statements[:0]
+
+	for i, s := range statements {
+		if is, ok := s.(*ast.IfStatement); ok {
+			if condition, ok := is.Condition.(*ast.Boolean); ok {
+				switch {
+				case condition.Value:
+					// the branch is evaluated in a scope of its own either way
+					s = is.Consequence
+				case i < len(statements)-1:
+					continue
+				}
+			}
+		}
+
+		result = append(result, s)
+
+		if block && returns(s) {
+			break
+		}
+	}
+
+	return result
+}
+
+// Reports whether evaluating s always ends with a return.
+func returns(s ast.Statement) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.BlockStatement:
+		for _, statement := range s.Statements {
+			if returns(statement) {
+				return true
+			}
+		}
+	case *ast.IfStatement:
+		return s.Alternative != nil &&
+			returns(s.Consequence) && returns(s.Alternative)
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package optimize_test
+
+import (
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/optimize"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	return program
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// constant folding
+		{"1 + 2 * 3;", "7"},
+		{"var x = (10 - 4) / 2 % 2;", "var x = 1;"},
+		{"2 ** 10 | 1 << 2 ^ ~0;", "-5"},
+		{"-(5) + 0xFF;", "250"},
+		{"1 < 2 == true;", "true"},
+		{"!(3 >= 4) && 2 != 2;", "false"},
+		{"9223372036854775807 + 1;", "9223372036854775808"},
+		{"x + 1 + 2;", "((x + 1) + 2)"},
+		{"x + (1 + 2);", "(x + 3)"},
+		{"false && f();", "false"},
+		{"true || f();", "true"},
+		{"true && f();", "(true && f())"},
+
+		// errors are left for the program to report
+		{"1 / 0;", "(1 / 0)"},
+		{"var y = 1 + (2 % 0);", "var y = (1 + (2 % 0));"},
+		{"1 + true;", "(1 + true)"},
+		{"!5;", "(!5)"},
+		{"1 << 100000000000;", "(1 << 100000000000)"},
+		{"if (false) { 2 ** 10000000000; }", "iffalse (2 ** 10000000000)"},
+
+		// conditional expressions
+		{"1 < 2 ? a : b;", "a"},
+		{"1 > 2 ? a : b + (2 * 2);", "(b + 4)"},
+		{"c ? 1 + 1 : 3;", "(c ? 2 : 3)"},
+
+		// if statements
+		{"if (1 < 2) { a; } else { b; }", "a"},
+		{"if (1 > 2) { a; } else { b; }", "b"},
+		{"if (false) { a; } c;", "c"},
+		{"if (false) { a; }", "iffalse a"},
+		{"if (x) { 1 + 1; } else { if (true) { b; } }", "ifx 2else b"},
+		{"var v = if (false) { 1 } else { 2 };", "var v = iftrue 2;"},
+		{"var w = if (true) { 1 } else { 2 };", "var w = iftrue 1;"},
+
+		// statements after a return
+		{"func f(n) { return n; n + 1; }", "func (n) return n;"},
+		{"func f(n) { if (n) { return 1; } else { return 2; } 3; }",
+			"func (n) ifn return 1;else return 2;"},
+		{"func f(n) { if (n) { return 1; } 3; }",
+			"func (n) ifn return 1;3"},
+		{"func f(n) { if (true) { return 1; } 3; }", "func (n) return 1;"},
+		{"func f(n) { { return 1; } 3; }", "func (n) return 1;"},
+		{"return 1; 2;", "return 1;2"},
+	}
+
+	for index, test := range tests {
+		program := optimize.Program(parse(t, test.input))
+		if got := program.String(); got != test.expected {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, test.expected, got)
+		}
+	}
+}
+
+func TestProgramPositions(t *testing.T) {
+	program := optimize.Program(parse(t, "var x =\n  1 + 2;"))
+
+	vds := program.Statements[0].(*ast.VariableDeclarationStatement)
+	literal, ok := vds.Value.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expected *ast.IntegerLiteral got=%T", vds.Value)
+	}
+
+	if literal.Token.Line != 2 || literal.Token.Column != 5 {
+		t.Errorf("expected the position of the operator 2:5 got=%d:%d",
+			literal.Token.Line, literal.Token.Column)
+	}
+}
+
+func TestProgramPreservesResults(t *testing.T) {
+	tests := []string{
+		"var x = 2 * 3 + 1; x * (4 - 2);",
+		"func f(n) { if (1 < 2) { return n * 2; } return 0; } f(21);",
+		`func fact(n) {
+		   if (n < 2) { return 1; }
+		   return n * fact(n - 1);
+		 }
+		 fact(5 * 4);`,
+		"var v = match (2 + 2) { 4 => 1 == 1, _ => false }; v;",
+		"if (false) { 1; }",
+		"1 / 0;",
+		"var a = 10; var b = if (a > 5 * 2) { a } else { 0 - a }; b;",
+	}
+
+	for index, test := range tests {
+		expected := evaluator.Eval(parse(t, test), object.NewEnvironment())
+
+		program := optimize.Program(parse(t, test))
+		got := evaluator.Eval(program, object.NewEnvironment())
+
+		if got.Inspect() != expected.Inspect() {
+			t.Errorf("tests[%d]: expected %q got=%q",
+				index, expected.Inspect(), got.Inspect())
+		}
+	}
+}
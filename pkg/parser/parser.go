@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/freddiehaddad/corrosion/pkg/ast"
@@ -17,16 +19,28 @@ const (
 	_ int = iota
 	LOWEST
 	ASSIGN
+	TERNARY
+	OR
+	AND
 	EQ
 	LTGT
+	BITOR
+	BITXOR
+	BITAND
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	EXPONENT
+	POSTFIX
 	CALL
 )
 
 var precedences = map[token.TokenType]int{
 	token.ASSIGN:   ASSIGN,
+	token.QUESTION: TERNARY,
+	token.OR:       OR,
+	token.AND:      AND,
 	token.LT:       LTGT,
 	token.GT:       LTGT,
 	token.LT_EQUAL: LTGT,
@@ -37,7 +51,23 @@ var precedences = map[token.TokenType]int{
 	token.PLUS:     SUM,
 	token.MULTIPLY: PRODUCT,
 	token.DIVIDE:   PRODUCT,
+	token.MODULO:   PRODUCT,
+	token.EXPONENT: EXPONENT,
 	token.LPAREN:   CALL,
+
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.MULTIPLY_ASSIGN: ASSIGN,
+	token.DIVIDE_ASSIGN:   ASSIGN,
+	token.MODULO_ASSIGN:   ASSIGN,
+	token.INCREMENT:       POSTFIX,
+	token.DECREMENT:       POSTFIX,
+
+	token.BITWISE_OR:  BITOR,
+	token.BITWISE_XOR: BITXOR,
+	token.BITWISE_AND: BITAND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -74,6 +104,12 @@ type Parser struct {
 	currentToken   token.Token
 	peekToken      token.Token
 	errors         []string
+	warnings       []string
+
+	// Names declared in each enclosing scope, innermost last, mapped to
+	// whether the binding is constant.  Used to reject assignments to
+	// constants that are visible at parse time.
+	scopes []map[string]bool
 }
 
 // ----------------------------------------------------------------------------
@@ -81,16 +117,33 @@ type Parser struct {
 // ----------------------------------------------------------------------------
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []string{}, warnings: []string{}}
 	p.nextToken()
 	p.nextToken()
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.MULTIPLY_ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.DIVIDE_ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.MODULO_ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.INCREMENT, p.parsePostfixExpression)
+	p.registerInfix(token.DECREMENT, p.parsePostfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MULTIPLY, p.parseInfixExpression)
 	p.registerInfix(token.DIVIDE, p.parseInfixExpression)
+	p.registerInfix(token.MODULO, p.parseInfixExpression)
+	p.registerInfix(token.EXPONENT, p.parseInfixExpression)
+	p.registerInfix(token.BITWISE_AND, p.parseInfixExpression)
+	p.registerInfix(token.BITWISE_OR, p.parseInfixExpression)
+	p.registerInfix(token.BITWISE_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -104,9 +157,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INTEGER, p.parseInteger)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.BITWISE_NOT, p.parsePrefixExpression)
+	p.registerPrefix(token.INCREMENT, p.parseIncrementExpression)
+	p.registerPrefix(token.DECREMENT, p.parseIncrementExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	return p
 }
@@ -116,6 +175,9 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 
+	p.enterScope()
+	defer p.leaveScope()
+
 	for !p.eof() {
 		stmt := p.parseStatement()
 		if stmt != nil {
@@ -124,6 +186,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	if err := p.l.Err(); err != nil {
+		p.error(fmt.Sprintf("reading input: %s", err))
+	}
+
 	return program
 }
 
@@ -133,7 +199,7 @@ func (p *Parser) parseStatement() ast.Statement {
 	var stmt ast.Statement
 
 	switch p.currentToken.Type {
-	case token.VAR:
+	case token.VAR, token.CONST:
 		stmt = p.parseVariableDeclarationStatement()
 	case token.FUNC:
 		stmt = p.parseFunctionDeclarationStatement()
@@ -141,6 +207,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		stmt = p.parseReturnStatement()
 	case token.IF:
 		stmt = p.parseIfStatement()
+	case token.MATCH:
+		stmt = p.parseMatchStatement()
+	case token.LBRACE:
+		stmt = p.parseBlockStatement()
 	default:
 		stmt = p.parseExpressionStatement()
 	}
@@ -194,6 +264,43 @@ func (p *Parser) nextToken() {
 	p.peekToken = p.l.NextToken()
 }
 
+// ----------------------------------------------------------------------------
+// Scope tracking
+// ----------------------------------------------------------------------------
+
+func (p *Parser) enterScope() {
+	p.scopes = append(p.scopes, make(map[string]bool))
+}
+
+func (p *Parser) leaveScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// Records the declaration of name in the innermost scope.
+func (p *Parser) declare(name string, constant bool) {
+	if len(p.scopes) > 0 {
+		p.scopes[len(p.scopes)-1][name] = constant
+	}
+}
+
+// Returns true if the innermost visible declaration of name is a constant.
+func (p *Parser) isConstant(name string) bool {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if constant, ok := p.scopes[i][name]; ok {
+			return constant
+		}
+	}
+	return false
+}
+
+// Records an error if exp is an identifier bound to a constant.
+func (p *Parser) checkAssignable(exp ast.Expression) {
+	if id, ok := exp.(*ast.Identifier); ok && p.isConstant(id.Value) {
+		msg := fmt.Sprintf("cannot assign to constant %q", id.Value)
+		p.error(msg)
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Pratt parser semantic code registering
 // ----------------------------------------------------------------------------
@@ -206,6 +313,15 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+func (p *Parser) incrementError(op string, exp ast.Expression) {
+	operand := "<nil>"
+	if exp != nil {
+		operand = exp.String()
+	}
+	msg := fmt.Sprintf("cannot apply %s to expression %q", op, operand)
+	p.error(msg)
+}
+
 func (p *Parser) noPrefixParseFnError(tt token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %q found", tt)
 	p.error(msg)
@@ -223,6 +339,16 @@ func (p *Parser) error(msg string) {
 	p.errors = append(p.errors, msg)
 }
 
+// Warnings returns diagnostics that do not prevent the program from being
+// evaluated, such as a match expression that may not be exhaustive.
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
+func (p *Parser) warning(msg string) {
+	p.warnings = append(p.warnings, msg)
+}
+
 // ----------------------------------------------------------------------------
 // Statement parsing functions
 // ----------------------------------------------------------------------------
@@ -247,6 +373,8 @@ func (p *Parser) parseVariableDeclarationStatement() ast.Statement {
 	ds.Value = p.parseExpression(LOWEST)
 	p.expectPeek(token.SEMICOLON)
 
+	p.declare(ds.Name.Value, ds.Constant())
+
 	return ds
 }
 
@@ -289,6 +417,7 @@ func (p *Parser) parseFunctionDeclarationStatement() ast.Statement {
 		Token: p.currentToken,
 		Value: p.currentToken.Literal,
 	}
+	p.declare(fds.Name.Value, false)
 
 	if !p.expectPeek(token.LPAREN) {
 		return nil
@@ -304,11 +433,33 @@ func (p *Parser) parseFunctionDeclarationStatement() ast.Statement {
 		return nil
 	}
 
+	p.enterScope()
+	for _, parameter := range fds.Parameters {
+		p.declare(parameter.Value, false)
+	}
 	fds.Body = p.parseBlockStatement()
+	p.leaveScope()
 
 	return &fds
 }
 
+// Parses a match expression appearing in statement position where, like an if
+// statement, the trailing semicolon is optional.
+func (p *Parser) parseMatchStatement() ast.Statement {
+	es := &ast.ExpressionStatement{Token: p.currentToken}
+
+	es.Expression = p.parseMatchExpression()
+	if es.Expression == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return es
+}
+
 func (p *Parser) parseReturnStatement() ast.Statement {
 	rs := &ast.ReturnStatement{Token: p.currentToken} // return
 
@@ -336,6 +487,7 @@ func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
 		p.error(msg)
 		return nil
 	}
+	p.checkAssignable(left)
 
 	p.nextToken()
 	a.Right = p.parseExpression(LOWEST)
@@ -349,11 +501,34 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.currentPrecedence()
+	if p.currentTokenIs(token.EXPONENT) {
+		precedence-- // right associative
+	}
 	p.nextToken()
 	e.Right = p.parseExpression(precedence)
 	return e
 }
 
+func (p *Parser) parseConditionalExpression(
+	condition ast.Expression,
+) ast.Expression {
+	ce := &ast.ConditionalExpression{
+		Token:     p.currentToken, // '?'
+		Condition: condition,
+	}
+
+	p.nextToken()
+	ce.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	p.nextToken()
+
+	ce.Alternative = p.parseExpression(TERNARY - 1) // right associative
+	return ce
+}
+
 func (p *Parser) parseFunctionCall(left ast.Expression) ast.Expression {
 	fce := &ast.FunctionCallExpression{
 		Token:    p.currentToken, // '('
@@ -406,6 +581,169 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return pe
 }
 
+// Parses prefix ++ and --, whose operand must be assignable.
+func (p *Parser) parseIncrementExpression() ast.Expression {
+	pe := &ast.PrefixExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+	}
+	p.nextToken() // ++
+	pe.Right = p.parseExpression(PREFIX)
+
+	if _, ok := pe.Right.(*ast.Identifier); !ok {
+		p.incrementError(pe.Operator, pe.Right)
+		return nil
+	}
+	p.checkAssignable(pe.Right)
+
+	return pe
+}
+
+// Parses postfix ++ and --, whose operand must be assignable.
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	pe := &ast.PostfixExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Left:     left,
+	}
+
+	if _, ok := left.(*ast.Identifier); !ok {
+		p.incrementError(pe.Operator, left)
+		return nil
+	}
+	p.checkAssignable(left)
+
+	return pe
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	me := &ast.MatchExpression{Token: p.currentToken} // 'match'
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	me.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		me.Arms = append(me.Arms, *arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken() // '}'
+
+	p.checkMatchExhaustive(me)
+
+	return me
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	p.enterScope()
+	defer p.leaveScope()
+
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if id, ok := arm.Pattern.(*ast.Identifier); ok {
+		p.declare(id.Value, false)
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	p.nextToken()
+	arm.Body = &ast.ExpressionStatement{
+		Token:      p.currentToken,
+		Expression: p.parseExpression(LOWEST),
+	}
+
+	return arm
+}
+
+// Parses a match pattern: an integer or boolean literal, an identifier that
+// binds the matched value, or the wildcard _.
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.currentToken.Type {
+	case token.INTEGER:
+		return p.parseInteger()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.IDENT:
+		return p.parseIdentifier()
+	case token.WILDCARD:
+		return &ast.Wildcard{Token: p.currentToken}
+	case token.MINUS:
+		if p.peekTokenIs(token.INTEGER) {
+			return p.parsePrefixExpression()
+		}
+	}
+
+	msg := fmt.Sprintf("invalid match pattern %q", p.currentToken.Literal)
+	p.error(msg)
+	return nil
+}
+
+// Records a warning if the arms of me are certain not to cover every value.
+// That is the case when no arm has a guard, no arm is a wildcard or binding,
+// and the literal patterns do not cover both boolean values.  When any arm has
+// a guard, exhaustiveness cannot be determined and no warning is recorded.
+func (p *Parser) checkMatchExhaustive(me *ast.MatchExpression) {
+	var hasTrue, hasFalse bool
+
+	for _, arm := range me.Arms {
+		if arm.Guard != nil {
+			return
+		}
+
+		switch pattern := arm.Pattern.(type) {
+		case *ast.Wildcard, *ast.Identifier:
+			return
+		case *ast.Boolean:
+			hasTrue = hasTrue || pattern.Value
+			hasFalse = hasFalse || !pattern.Value
+		}
+	}
+
+	if hasTrue && hasFalse {
+		return
+	}
+
+	msg := fmt.Sprintf("match (%s) is not exhaustive; "+
+		"add a _ arm to handle all values", me.Subject.String())
+	p.warning(msg)
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.currentToken,
@@ -422,6 +760,9 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseInteger() ast.Expression {
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return p.parseBigInteger()
+	}
 	if err != nil {
 		p.error(err.Error())
 		return nil
@@ -433,6 +774,50 @@ func (p *Parser) parseInteger() ast.Expression {
 	}
 }
 
+// Reports a token the lexer could not recognize, explaining what is wrong
+// with malformed numbers.
+func (p *Parser) parseIllegal() ast.Expression {
+	tok := p.currentToken
+
+	msg := fmt.Sprintf("illegal token %q at line %d, column %d",
+		tok.Literal, tok.Line, tok.Column)
+	if tok.Literal[0] >= '0' && tok.Literal[0] <= '9' {
+		if err := lexer.NumberError(tok.Literal); err != nil {
+			msg = fmt.Sprintf("malformed number %q at line %d, column %d: %s",
+				tok.Literal, tok.Line, tok.Column, err)
+		}
+	}
+
+	p.error(msg)
+	return nil
+}
+
+// Parses integer literals that overflow an int64 into an arbitrary-precision
+// literal.
+func (p *Parser) parseBigInteger() ast.Expression {
+	value, ok := new(big.Int).SetString(p.currentToken.Literal, 0)
+	if !ok {
+		msg := fmt.Sprintf("could not parse %q as integer",
+			p.currentToken.Literal)
+		p.error(msg)
+		return nil
+	}
+
+	return &ast.BigIntegerLiteral{
+		Token: p.currentToken,
+		Value: value,
+	}
+}
+
+// Parses an if statement appearing in expression position.
+func (p *Parser) parseIfExpression() ast.Expression {
+	is := p.parseIfStatement()
+	if is == nil {
+		return nil
+	}
+	return is
+}
+
 func (p *Parser) parseIfStatement() *ast.IfStatement {
 	is := &ast.IfStatement{Token: p.currentToken} // 'if'
 
@@ -467,6 +852,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	bs := &ast.BlockStatement{Token: p.currentToken} // '{'
 	p.nextToken()                                    // '{'
 
+	p.enterScope()
+	defer p.leaveScope()
+
 	bs.Statements = []ast.Statement{}
 	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
 		stmt := p.parseStatement()
@@ -482,7 +870,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	es := &ast.ExpressionStatement{Token: p.currentToken}
 	es.Expression = p.parseExpression(LOWEST)
-	p.expectPeek(token.SEMICOLON)
+
+	// the semicolon is optional on the last expression of a block
+	if !p.peekTokenIs(token.RBRACE) {
+		p.expectPeek(token.SEMICOLON)
+	}
 	return es
 }
 
@@ -287,6 +287,74 @@ func TestVariableDeclarationStatement(t *testing.T) {
 	checkStatements(t, expected, program.Statements)
 }
 
+func TestConstantDeclarationStatement(t *testing.T) {
+	input := "const x = 5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	checkProgram(t, program)
+	checkErrors(t, p)
+	checkLength(t, 1, program.Statements)
+	checkStatements(t, testResults{{"const", "x", "5"}}, program.Statements)
+
+	ds := program.Statements[0].(*ast.VariableDeclarationStatement)
+	if !ds.Constant() {
+		t.Errorf("expected constant declaration")
+	}
+
+	if program.String() != input {
+		t.Errorf("program.String() returned %q, expected %q",
+			program.String(), input)
+	}
+}
+
+func TestConstantAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 1; x = 2;", `cannot assign to constant "x"`},
+		{"const x = 1; x += 2;", `cannot assign to constant "x"`},
+		{"const x = 1; x++;", `cannot assign to constant "x"`},
+		{"const x = 1; --x;", `cannot assign to constant "x"`},
+		{
+			"const x = 1; func f() { x = 2; }",
+			`cannot assign to constant "x"`,
+		},
+		{
+			"const x = 1; if (true) { x = 2; }",
+			`cannot assign to constant "x"`,
+		},
+		{"var x = 1; x = 2;", ""},
+		{"const x = 1; func f(x) { x = 2; }", ""},
+		{"const x = 1; func f() { var x = 0; x = 2; }", ""},
+		{"const x = 1; match (2) { x => x++ };", ""},
+		{"func f() { y = 2; } const y = 1;", ""},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if test.expected == "" {
+			if len(errors) != 0 {
+				t.Errorf("tests[%d]: unexpected errors %v",
+					index, errors)
+			}
+			continue
+		}
+
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("tests[%d]: expected error %q got=%v",
+				index, test.expected, errors)
+		}
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := "return x;"
 	expected := testResults{{"return", "x"}}
@@ -533,6 +601,81 @@ func TestAssignment(t *testing.T) {
 	}
 }
 
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x += 2;", "(x += 2)"},
+		{"x -= 2 * y;", "(x -= (2 * y))"},
+		{"x *= y /= z %= 3;", "(x *= (y /= (z %= 3)))"},
+		{"x++;", "(x++)"},
+		{"x--;", "(x--)"},
+		{"++x;", "(++x)"},
+		{"--x;", "(--x)"},
+		{"-x++;", "(-(x++))"},
+		{"x++ + ++y;", "((x++) + (++y))"},
+		{"a+++b;", "((a++) + b)"},
+		{"x += y++;", "(x += (y++))"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		checkProgram(t, program)
+		checkErrors(t, p)
+		checkLength(t, 1, program.Statements)
+
+		if program.Statements[0].String() != test.expected {
+			t.Errorf(`tests[%d]: parser tree incorrect.
+				expected=%q got=%q`,
+				index,
+				test.expected,
+				program.Statements[0].String())
+		}
+	}
+}
+
+func TestIncrementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5++;", `cannot apply ++ to expression "5"`},
+		{"--5;", `cannot apply -- to expression "5"`},
+		{"f()++;", `cannot apply ++ to expression "f()"`},
+		{"1 += 2;", `cannot assign to expression "1"`},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("tests[%d]: expected error %q got=%v",
+				index, test.expected, errors)
+		}
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []string{"1 +", "var x =", "f(1,", "func f("}
+
+	for index, test := range tests {
+		l := lexer.New(test)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("tests[%d]: expected errors for %q", index, test)
+		}
+	}
+}
+
 func TestParentheses(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -628,6 +771,116 @@ func TestParseIfStatement(t *testing.T) {
 	}
 }
 
+func TestIfExpressionAndConditional(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var x = if (a) { 1 } else { 2 };", "var x = ifa 1else 2;"},
+		{"x = if (a) { 1; };", "(x = ifa 1)"},
+		{"a ? b : c;", "(a ? b : c)"},
+		{"a ? b : c ? d : e;", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e;", "(a ? (b ? c : d) : e)"},
+		{"a == b ? c + 1 : d * 2;", "((a == b) ? (c + 1) : (d * 2))"},
+		{"a || b ? c : d;", "((a || b) ? c : d)"},
+		{"x = a ? b : c;", "(x = (a ? b : c))"},
+		{"f(a ? b : c, d);", "f((a ? b : c), d)"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		checkProgram(t, program)
+		checkErrors(t, p)
+		checkLength(t, 1, program.Statements)
+
+		if program.Statements[0].String() != test.expected {
+			t.Errorf(`tests[%d]: parser tree incorrect.
+				expected=%q got=%q`,
+				index,
+				test.expected,
+				program.Statements[0].String())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"match (x) { 1 => a, -2 => b, _ => c }",
+			"match (x) { 1 => a, (-2) => b, _ => c }",
+		},
+		{
+			"var y = match (x + 1) { true => 1, false => 2, };",
+			"var y = match ((x + 1)) { true => 1, false => 2 };",
+		},
+		{
+			"match (x) { n if n > 0 => { var z = n; z * 2 } _ => 0 };",
+			"",
+		},
+		{
+			"match (x) { n if n > 0 => { var z = n; z * 2 }, _ => 0 };",
+			"match (x) { n if (n > 0) => var z = n;(z * 2), _ => 0 }",
+		},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if test.expected == "" {
+			if len(p.Errors()) == 0 {
+				t.Errorf("tests[%d]: expected parser errors", index)
+			}
+			continue
+		}
+
+		checkProgram(t, program)
+		checkErrors(t, p)
+		checkLength(t, 1, program.Statements)
+
+		if program.Statements[0].String() != test.expected {
+			t.Errorf(`tests[%d]: parser tree incorrect.
+				expected=%q got=%q`,
+				index,
+				test.expected,
+				program.Statements[0].String())
+		}
+	}
+}
+
+func TestMatchExhaustivenessWarning(t *testing.T) {
+	tests := []struct {
+		input    string
+		warnings int
+	}{
+		{"match (x) { 1 => a, 2 => b }", 1},
+		{"match (x) { true => a }", 1},
+		{"match (x) { true => a, false => b }", 0},
+		{"match (x) { 1 => a, _ => b }", 0},
+		{"match (x) { 1 => a, n => b }", 0},
+		{"match (x) { n if n > 0 => a, 0 => b }", 0},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		p.ParseProgram()
+		checkErrors(t, p)
+
+		if len(p.Warnings()) != test.warnings {
+			t.Errorf("tests[%d]: expected %d warnings got=%v",
+				index, test.warnings, p.Warnings())
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 	expected := testResults{{"foobar"}}
@@ -656,6 +909,99 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	checkStatements(t, expected, program.Statements)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "9223372036854775808;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	checkProgram(t, program)
+	checkErrors(t, p)
+	checkLength(t, 1, program.Statements)
+
+	es, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("expected ast.ExpressionStatement got=%T",
+			program.Statements[0])
+	}
+
+	bi, ok := es.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("expected ast.BigIntegerLiteral got=%T", es.Expression)
+	}
+
+	if bi.Value.String() != "9223372036854775808" {
+		t.Errorf("incorrect value. expected=%q got=%q",
+			"9223372036854775808", bi.Value.String())
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF;", 255},
+		{"0Xff;", 255},
+		{"0o17;", 15},
+		{"017;", 15},
+		{"0b101;", 5},
+		{"1_000_000;", 1000000},
+		{"0x_FF_FF;", 65535},
+		{"0;", 0},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		checkErrors(t, p)
+		checkLength(t, 1, program.Statements)
+
+		es := program.Statements[0].(*ast.ExpressionStatement)
+		il, ok := es.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("tests[%d]: expected ast.IntegerLiteral got=%T",
+				index, es.Expression)
+		}
+		if il.Value != test.expected {
+			t.Errorf("tests[%d]: expected %d got=%d",
+				index, test.expected, il.Value)
+		}
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x;", `malformed number "0x" at line 1, column 1: ` +
+			`hexadecimal literal has no digits`},
+		{"1 + 12abc;", `malformed number "12abc" at line 1, column 5: ` +
+			`invalid digit 'a' in decimal literal`},
+		{"0b102;", `malformed number "0b102" at line 1, column 1: ` +
+			`invalid digit '2' in binary literal`},
+		{"1__000;", `malformed number "1__000" at line 1, column 1: ` +
+			`'_' must separate successive digits`},
+		{"var x = $;", `illegal token "$" at line 1, column 9`},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("tests[%d]: expected error %q got=%v",
+				index, test.expected, errors)
+		}
+	}
+}
+
 func TestEqualityExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -959,10 +1305,57 @@ func TestInfixOperatorPrecedence(t *testing.T) {
 	checkStatements(t, expected, program.Statements)
 }
 
+func TestArithmeticAndBitwiseOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a % b * c;", "((a % b) * c)"},
+		{"a + b % c;", "(a + (b % c))"},
+		{"a ** b ** c;", "(a ** (b ** c))"},
+		{"a * b ** c;", "(a * (b ** c))"},
+		{"-a ** b;", "(-(a ** b))"},
+		{"a ** -b;", "(a ** (-b))"},
+		{"~a & b;", "((~a) & b)"},
+		{"a | b ^ c & d;", "(a | (b ^ (c & d)))"},
+		{"a & b | c ^ d;", "((a & b) | (c ^ d))"},
+		{"a << b + c;", "(a << (b + c))"},
+		{"a & b << c;", "(a & (b << c))"},
+		{"a >> b << c;", "((a >> b) << c)"},
+		{"a & b == c;", "((a & b) == c)"},
+		{"a | b < c;", "((a | b) < c)"},
+
+		{"a && b || c;", "((a && b) || c)"},
+		{"a || b && c;", "(a || (b && c))"},
+		{"a == b && c != d;", "((a == b) && (c != d))"},
+		{"a < b || c >= d;", "((a < b) || (c >= d))"},
+		{"!a && b;", "((!a) && b)"},
+		{"x = a || b;", "(x = (a || b))"},
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		checkProgram(t, program)
+		checkErrors(t, p)
+		checkLength(t, 1, program.Statements)
+
+		if program.Statements[0].String() != test.expected {
+			t.Errorf(`tests[%d]: parser tree incorrect.
+				expected=%q got=%q`,
+				index,
+				test.expected,
+				program.Statements[0].String())
+		}
+	}
+}
+
 func TestPrefixOperatorExpressions(t *testing.T) {
 	input := `
 		-10;
-		--5;
+		- -5;
 		`
 	expected := testResults{
 		{"-", "(-10)"},
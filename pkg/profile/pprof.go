@@ -0,0 +1,212 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// Field numbers from the perftools.profiles.Profile message.  See
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID        = 1
+	functionName      = 2
+	functionFilename  = 4
+	functionStartLine = 5
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// Writes the profile to w as a gzipped pprof protocol buffer.  Each sample
+// holds the number of calls and the self time of a call stack, with a
+// location for each line of a function the stack passes through.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var b buffer
+	table := newStringTable()
+
+	valueType := func(kind, unit string) []byte {
+		var v buffer
+		v.int(valueTypeType, table.index(kind))
+		v.int(valueTypeUnit, table.index(unit))
+		return v
+	}
+	b.bytes(profileSampleType, valueType("calls", "count"))
+	b.bytes(profileSampleType, valueType("time", "nanoseconds"))
+
+	// functions and locations are numbered from 1 in order of their names
+	// and lines so that the output is deterministic
+	functions := make([]*Function, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Name != functions[j].Name {
+			return functions[i].Name < functions[j].Name
+		}
+		return functions[i].Line < functions[j].Line
+	})
+	functionIDs := make(map[*Function]int64)
+	for i, f := range functions {
+		functionIDs[f] = int64(i + 1)
+	}
+
+	samples := make([]*sample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return less(samples[i].stack, samples[j].stack, functionIDs)
+	})
+
+	locationIDs := make(map[location]int64)
+	var locations []location
+	for _, s := range samples {
+		for _, l := range s.stack {
+			if _, ok := locationIDs[l]; !ok {
+				locations = append(locations, l)
+				locationIDs[l] = int64(len(locations))
+			}
+		}
+	}
+
+	for _, s := range samples {
+		var sb, ids, values buffer
+		for _, l := range s.stack {
+			ids.varint(uint64(locationIDs[l]))
+		}
+		values.varint(uint64(s.calls))
+		values.varint(uint64(s.time.Nanoseconds()))
+		sb.bytes(sampleLocationID, ids)
+		sb.bytes(sampleValue, values)
+		b.bytes(profileSample, sb)
+	}
+
+	for _, l := range locations {
+		var lb, line buffer
+		line.int(lineFunctionID, functionIDs[l.function])
+		line.int(lineLine, int64(l.line))
+		lb.int(locationID, locationIDs[l])
+		lb.bytes(locationLine, line)
+		b.bytes(profileLocation, lb)
+	}
+
+	for _, f := range functions {
+		var fb buffer
+		fb.int(functionID, functionIDs[f])
+		fb.int(functionName, table.index(f.Name))
+		fb.int(functionFilename, table.index(p.filename))
+		fb.int(functionStartLine, int64(f.Line))
+		b.bytes(profileFunction, fb)
+	}
+
+	b.int(profileTimeNanos, p.start.UnixNano())
+	b.int(profileDurationNanos, p.duration.Nanoseconds())
+	b.bytes(profilePeriodType, valueType("time", "nanoseconds"))
+	b.int(profilePeriod, 1)
+
+	// the string table is written last, once every string has an index
+	for _, s := range table.strings {
+		b.bytes(profileStringTable, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Orders call stacks by their function ids and lines, innermost first.
+func less(a, b []location, ids map[*Function]int64) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].function != b[i].function {
+			return ids[a[i].function] < ids[b[i].function]
+		}
+		if a[i].line != b[i].line {
+			return a[i].line < b[i].line
+		}
+	}
+	return len(a) < len(b)
+}
+
+// ----------------------------------------------------------------------------
+// Encoding
+// ----------------------------------------------------------------------------
+
+// The strings referenced by index from a profile.  The first must be empty.
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{
+		strings: []string{""},
+		indexes: map[string]int64{"": 0},
+	}
+}
+
+// Returns the index of s, adding it to the table if needed.
+func (t *stringTable) index(s string) int64 {
+	i, ok := t.indexes[s]
+	if !ok {
+		i = int64(len(t.strings))
+		t.strings = append(t.strings, s)
+		t.indexes[s] = i
+	}
+	return i
+}
+
+// buffer accumulates an encoded protocol buffer message.
+type buffer []byte
+
+func (b *buffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+// Appends an integer field.  Zero values are omitted, as they are the default.
+func (b *buffer) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.varint(uint64(field)<<3 | wireVarint)
+	b.varint(uint64(v))
+}
+
+// Appends a length delimited field, used for messages, strings and packed
+// repeated integers.
+func (b *buffer) bytes(field int, v []byte) {
+	b.varint(uint64(field)<<3 | wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
@@ -0,0 +1,265 @@
+// The profile package records where Corrosion programs spend their time.
+//
+// A Profiler is installed as the evaluator hook for the program environment.
+// It counts the calls to each function and measures their self and cumulative
+// time, and counts how many times the statements on each line are evaluated.
+// The results can be written as a text report or as a pprof profile for use
+// with go tool pprof.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+)
+
+// Name of the frame for statements outside of any function.
+const mainFunction = "<main>"
+
+// Function holds the measurements for one function.
+type Function struct {
+	Name       string
+	Line       int           // line on which the function body starts
+	Calls      int           // number of calls
+	Self       time.Duration // time spent in the function's own statements
+	Cumulative time.Duration // time spent in the function and its callees
+}
+
+// LineHits is the number of statements evaluated on a line.
+type LineHits struct {
+	Line int
+	Hits int
+}
+
+// The Profiler object represents the measurements of one program run.
+type Profiler struct {
+	filename  string
+	now       func() time.Time
+	start     time.Time
+	duration  time.Duration
+	stack     []frame
+	functions map[ast.Statement]*Function // by body, nil for <main>
+	active    map[*Function]int           // calls in progress
+	lines     map[int]int                 // statements evaluated, by line
+	samples   map[string]*sample
+}
+
+// An entry in the call stack.
+type frame struct {
+	function *Function
+	line     int           // line of the statement being evaluated
+	start    time.Time     // when the call was entered
+	children time.Duration // time spent in calls made from the frame
+}
+
+// The calls and self time recorded for one call stack.
+type sample struct {
+	stack []location // innermost first
+	calls int64
+	time  time.Duration
+}
+
+// A function and a line in it.
+type location struct {
+	function *Function
+	line     int
+}
+
+// Creates and returns a Profiler for the program read from filename, which is
+// used to label the profile.
+func New(filename string) *Profiler {
+	return &Profiler{
+		filename:  filename,
+		now:       time.Now,
+		functions: make(map[ast.Statement]*Function),
+		active:    make(map[*Function]int),
+		lines:     make(map[int]int),
+		samples:   make(map[string]*sample),
+	}
+}
+
+// Run evaluates program in env while profiling and returns the result.
+func (p *Profiler) Run(
+	program *ast.Program, env *object.Environment,
+) object.Object {
+	main := p.function(nil, mainFunction, 0)
+
+	p.start = p.now()
+	p.stack = []frame{{function: main, start: p.start}}
+
+	env.SetHook(p)
+	defer env.SetHook(nil)
+
+	result := evaluator.Eval(program, env)
+
+	end := p.now()
+	p.duration = end.Sub(p.start)
+
+	main.Calls = 1
+	main.Cumulative = p.duration
+	main.Self = p.duration - p.stack[0].children
+	p.record(0, main.Self)
+	p.stack = nil
+
+	return result
+}
+
+// Returns the measurements for each function, including the <main> frame for
+// statements outside of functions, by decreasing cumulative time.
+func (p *Profiler) Functions() []Function {
+	functions := make([]Function, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, *f)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Cumulative != functions[j].Cumulative {
+			return functions[i].Cumulative > functions[j].Cumulative
+		}
+		if functions[i].Name != functions[j].Name {
+			return functions[i].Name < functions[j].Name
+		}
+		return functions[i].Line < functions[j].Line
+	})
+
+	return functions
+}
+
+// Returns the hit counts for each line that was evaluated, in line order.
+func (p *Profiler) Lines() []LineHits {
+	lines := make([]LineHits, 0, len(p.lines))
+	for line, hits := range p.lines {
+		lines = append(lines, LineHits{Line: line, Hits: hits})
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Line < lines[j].Line
+	})
+
+	return lines
+}
+
+// Writes a readable report of the function and line measurements to w.
+func (p *Profiler) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(tw, "calls\tself\tself%%\tcum\tcum%%\t  function\n")
+	for _, f := range p.Functions() {
+		fmt.Fprintf(tw, "%d\t%v\t%s\t%v\t%s\t  %s\n",
+			f.Calls, f.Self, p.percent(f.Self),
+			f.Cumulative, p.percent(f.Cumulative), f.Name)
+	}
+	fmt.Fprintf(tw, "\n")
+
+	fmt.Fprintf(tw, "line\thits\t\n")
+	for _, l := range p.Lines() {
+		fmt.Fprintf(tw, "%d\t%d\t\n", l.Line, l.Hits)
+	}
+
+	return tw.Flush()
+}
+
+// Formats d as a percentage of the running time.
+func (p *Profiler) percent(d time.Duration) string {
+	if p.duration <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(p.duration))
+}
+
+// ----------------------------------------------------------------------------
+// Evaluator hook
+// ----------------------------------------------------------------------------
+
+// Statement is called by the evaluator before each statement.  It counts the
+// hit on the statement's line.
+func (p *Profiler) Statement(node ast.Statement, env *object.Environment) {
+	line := ast.Line(node)
+	p.lines[line]++
+	p.stack[len(p.stack)-1].line = line
+}
+
+// EnterCall is called by the evaluator when a function call begins.  The call
+// is counted for the function's declaration, whatever name it is called by.
+func (p *Profiler) EnterCall(
+	node *ast.FunctionCallExpression,
+	fn *object.Function,
+	env *object.Environment,
+) {
+	f := p.function(fn.Body, fn.Name, ast.Line(fn.Body))
+	f.Calls++
+	p.active[f]++
+
+	p.stack = append(p.stack, frame{
+		function: f,
+		line:     f.Line,
+		start:    p.now(),
+	})
+}
+
+// ExitCall is called by the evaluator when a function call returns.
+func (p *Profiler) ExitCall(
+	node *ast.FunctionCallExpression, result object.Object,
+) {
+	top := len(p.stack) - 1
+	call := p.stack[top]
+	elapsed := p.now().Sub(call.start)
+
+	f := call.function
+	self := elapsed - call.children
+	f.Self += self
+
+	// time in a recursive call is already counted by the outermost call
+	p.active[f]--
+	if p.active[f] == 0 {
+		f.Cumulative += elapsed
+	}
+
+	p.record(top, self)
+	p.stack = p.stack[:top]
+	p.stack[top-1].children += elapsed
+}
+
+// Returns the measurements for the function with body, creating them with
+// name and line if needed.  Functions are told apart by their bodies, so the
+// closures created by one declaration share their measurements while
+// different functions declared with the same name do not.
+func (p *Profiler) function(
+	body ast.Statement, name string, line int,
+) *Function {
+	f, ok := p.functions[body]
+	if !ok {
+		f = &Function{Name: name, Line: line}
+		p.functions[body] = f
+	}
+	return f
+}
+
+// Adds a call of the frame at index taking self time to the sample for the
+// call stack ending there.  The <main> frame is not counted as a call.
+func (p *Profiler) record(index int, self time.Duration) {
+	stack := make([]location, 0, index+1)
+	key := ""
+	for i := index; i >= 0; i-- {
+		l := location{function: p.stack[i].function, line: p.stack[i].line}
+		stack = append(stack, l)
+		key += fmt.Sprintf("%p:%d;", l.function, l.line)
+	}
+
+	s, ok := p.samples[key]
+	if !ok {
+		s = &sample{stack: stack}
+		p.samples[key] = s
+	}
+
+	if index > 0 {
+		s.calls++
+	}
+	s.time += self
+}
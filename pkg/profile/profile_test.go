@@ -0,0 +1,290 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+const program = `func fact(n) {
+  if (n < 2) {
+    return 1;
+  }
+  return n * fact(n - 1);
+}
+func twice(n) {
+  return fact(n) + fact(n);
+}
+twice(3);`
+
+// Runs program under a profiler whose clock advances a millisecond each time
+// it is read.
+func profile(t *testing.T, input string) *Profiler {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	clock := time.Unix(0, 0)
+	profiler := New("program.cr")
+	profiler.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	result := profiler.Run(program, object.NewEnvironment())
+	if result.Type() == object.ERROR_OBJ {
+		t.Fatalf("unexpected error %s", result.Inspect())
+	}
+
+	return profiler
+}
+
+func TestFunctions(t *testing.T) {
+	profiler := profile(t, program)
+
+	// the clock is read on entering and leaving each of the 7 calls and at
+	// the start and end of the run
+	ms := time.Millisecond
+	tests := []Function{
+		{Name: "<main>", Line: 0, Calls: 1, Self: 2 * ms, Cumulative: 15 * ms},
+		{Name: "twice", Line: 7, Calls: 1, Self: 3 * ms, Cumulative: 13 * ms},
+		{Name: "fact", Line: 1, Calls: 6, Self: 10 * ms, Cumulative: 10 * ms},
+	}
+
+	functions := profiler.Functions()
+	if len(functions) != len(tests) {
+		t.Fatalf("expected %d functions. got=%d", len(tests), len(functions))
+	}
+
+	for index, expected := range tests {
+		if functions[index] != expected {
+			t.Errorf("tests[%d]: expected %+v. got=%+v",
+				index, expected, functions[index])
+		}
+	}
+}
+
+// Calls are counted for the function declared, whatever name it is called by,
+// and functions declared with the same name in different scopes are counted
+// separately.
+func TestFunctionsByDeclaration(t *testing.T) {
+	profiler := profile(t, `func fib(n) {
+  if (n < 2) { return n; }
+  return fib(n - 1) + fib(n - 2);
+}
+func mk() { func fib(n) { return n; } return fib; }
+var f = fib;
+f(3);
+fib(2);
+mk()(1);`)
+
+	tests := []struct {
+		name  string
+		line  int
+		calls int
+	}{
+		{"<main>", 0, 1},
+		{"fib", 1, 8},
+		{"fib", 5, 1},
+		{"mk", 5, 1},
+	}
+
+	functions := profiler.Functions()
+	if len(functions) != len(tests) {
+		t.Fatalf("expected %d functions. got=%+v", len(tests), functions)
+	}
+
+	for index, expected := range tests {
+		found := false
+		for _, f := range functions {
+			if f.Name == expected.name && f.Line == expected.line {
+				found = f.Calls == expected.calls
+			}
+		}
+		if !found {
+			t.Errorf("tests[%d]: expected %s on line %d with %d calls. "+
+				"got=%+v", index, expected.name, expected.line,
+				expected.calls, functions)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	profiler := profile(t, program)
+
+	expected := []LineHits{
+		{1, 1}, {2, 6}, {3, 2}, {5, 4}, {7, 1}, {8, 1}, {10, 1},
+	}
+
+	lines := profiler.Lines()
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %v. got=%v", expected, lines)
+	}
+	for index, line := range expected {
+		if lines[index] != line {
+			t.Errorf("tests[%d]: expected %v. got=%v",
+				index, line, lines[index])
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	profiler := profile(t, program)
+
+	var out bytes.Buffer
+	if err := profiler.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"  calls  self  self%   cum    cum%  function",
+		"      1   2ms  13.3%  15ms  100.0%  <main>",
+		"      1   3ms  20.0%  13ms   86.7%  twice",
+		"      6  10ms  66.7%  10ms   66.7%  fact",
+		"",
+		"  line  hits",
+		"     1     1",
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for index, line := range expected {
+		if index >= len(lines) || lines[index] != line {
+			t.Fatalf("expected report to start with\n%s\ngot=\n%s",
+				strings.Join(expected, "\n"), out.String())
+		}
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	profiler := profile(t, program)
+
+	var out bytes.Buffer
+	if err := profiler.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := decode(t, data)
+
+	table := []string{}
+	for _, f := range fields[profileStringTable] {
+		table = append(table, string(f.([]byte)))
+	}
+	if len(table) == 0 || table[0] != "" {
+		t.Fatalf("expected the string table to start empty. got=%q", table)
+	}
+
+	// the sample values are calls and time in nanoseconds
+	var calls, nanoseconds int64
+	for _, f := range fields[profileSample] {
+		sample := decode(t, f.([]byte))
+		values := sample[sampleValue][0].([]byte)
+		for i, v := range packed(t, values) {
+			if i == 0 {
+				calls += v
+			} else {
+				nanoseconds += v
+			}
+		}
+	}
+	if calls != 7 {
+		t.Errorf("expected 7 calls in the samples. got=%d", calls)
+	}
+	if nanoseconds != (15 * time.Millisecond).Nanoseconds() {
+		t.Errorf("expected 15ms in the samples. got=%dns", nanoseconds)
+	}
+
+	names := []string{}
+	for _, f := range fields[profileFunction] {
+		function := decode(t, f.([]byte))
+		names = append(names, table[function[functionName][0].(uint64)])
+	}
+	if got := strings.Join(names, " "); got != "<main> fact twice" {
+		t.Errorf("expected functions <main> fact twice. got=%s", got)
+	}
+
+	// fact:3, fact:5, twice:8 and <main>:10
+	if n := len(fields[profileLocation]); n != 4 {
+		t.Errorf("expected 4 locations. got=%d", n)
+	}
+
+	duration := fields[profileDurationNanos][0].(uint64)
+	if duration != uint64((15 * time.Millisecond).Nanoseconds()) {
+		t.Errorf("expected a duration of 15ms. got=%dns", duration)
+	}
+}
+
+// Decodes the fields of a protocol buffer message.  Varints are returned as
+// uint64 and length delimited fields as []byte.
+func decode(t *testing.T, data []byte) map[int][]any {
+	t.Helper()
+
+	fields := make(map[int][]any)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid field key")
+		}
+		data = data[n:]
+
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("invalid varint in field %d", field)
+			}
+			data = data[n:]
+			fields[field] = append(fields[field], v)
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				t.Fatalf("invalid length in field %d", field)
+			}
+			data = data[n:]
+			fields[field] = append(fields[field], data[:length])
+			data = data[length:]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", key&7, field)
+		}
+	}
+
+	return fields
+}
+
+// Decodes packed repeated integers.
+func packed(t *testing.T, data []byte) []int64 {
+	t.Helper()
+
+	values := []int64{}
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid packed varint")
+		}
+		data = data[n:]
+		values = append(values, int64(v))
+	}
+	return values
+}
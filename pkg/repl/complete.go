@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/token"
+)
+
+// completion is a candidate for tab completion.  Hint is shown when listing
+// candidates, e.g. the signature of a function.
+type completion struct {
+	Name string
+	Hint string
+}
+
+// Returns the completions for prefix drawn from the names visible in env
+// (including outer scopes) and the language keywords, sorted by name.
+func complete(env *object.Environment, prefix string) []completion {
+	seen := make(map[string]bool)
+	var completions []completion
+
+	for e := env; e != nil; e = e.Outer() {
+		for _, name := range e.Names() {
+			if seen[name] || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			seen[name] = true
+
+			obj, _ := e.GetLocal(name)
+			completions = append(completions, completion{
+				Name: name,
+				Hint: hint(name, obj),
+			})
+		}
+	}
+
+	for _, keyword := range token.Keywords() {
+		if !seen[keyword] && strings.HasPrefix(keyword, prefix) {
+			completions = append(completions, completion{Name: keyword})
+		}
+	}
+
+	sort.Slice(completions, func(i, j int) bool {
+		return completions[i].Name < completions[j].Name
+	})
+
+	return completions
+}
+
+// Returns the signature of obj if it is a function, e.g. add(a, b).
+func hint(name string, obj object.Object) string {
+	fn, ok := obj.(*object.Function)
+	if !ok {
+		return ""
+	}
+
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.String()
+	}
+
+	return name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// Returns the longest prefix shared by all completions.
+func commonPrefix(completions []completion) string {
+	if len(completions) == 0 {
+		return ""
+	}
+
+	prefix := completions[0].Name
+	for _, c := range completions[1:] {
+		for !strings.HasPrefix(c.Name, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+
+	return prefix
+}
+
+// Returns true if r can be part of an identifier.
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,346 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Key codes
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// ----------------------------------------------------------------------------
+// Terminal reader
+// ----------------------------------------------------------------------------
+
+// terminalReader reads lines from a terminal in raw mode, providing cursor
+// movement, editing keys and history navigation.
+type terminalReader struct {
+	in          *bufio.Reader
+	out         io.Writer
+	fd          int
+	history     []string
+	historyPath string
+	complete    completer
+}
+
+func newTerminalReader(
+	f *os.File, out io.Writer, historyPath string, complete completer,
+) *terminalReader {
+	return &terminalReader{
+		in:          bufio.NewReader(f),
+		out:         out,
+		fd:          int(f.Fd()),
+		history:     loadHistory(historyPath),
+		historyPath: historyPath,
+		complete:    complete,
+	}
+}
+
+// Adds entry to the in-memory history and the history file, skipping
+// consecutive duplicates.
+func (t *terminalReader) AddHistory(entry string) {
+	if n := len(t.history); n > 0 && t.history[n-1] == entry {
+		return
+	}
+
+	t.history = append(t.history, entry)
+	if len(t.history) > historyLimit {
+		t.history = t.history[len(t.history)-historyLimit:]
+	}
+	appendHistory(t.historyPath, entry)
+}
+
+func (t *terminalReader) Close() error { return nil }
+
+// ReadLine displays prompt and reads a line with editing support.  Returns
+// io.EOF on Ctrl+D at an empty line and errInterrupt on Ctrl+C.
+func (t *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restoreTerminal(t.fd, state)
+
+	e := &lineEditor{
+		prompt:  prompt,
+		history: t.history,
+		index:   len(t.history),
+	}
+	t.refresh(e)
+
+	for {
+		r, _, err := t.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(t.out, "\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			fmt.Fprint(t.out, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(t.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete()
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.left()
+		case keyCtrlF:
+			e.right()
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = e.line[e.pos:]
+			e.pos = 0
+		case keyCtrlW:
+			e.deleteWord()
+		case keyCtrlP:
+			e.previous()
+		case keyCtrlN:
+			e.next()
+		case keyCtrlL:
+			fmt.Fprint(t.out, "\x1b[H\x1b[2J")
+		case keyBackspace, keyCtrlH:
+			e.backspace()
+		case keyTab:
+			t.completeWord(e)
+		case keyEscape:
+			t.escape(e)
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(r)
+			}
+		}
+
+		t.refresh(e)
+	}
+}
+
+// Handles ANSI escape sequences for the arrow, home, end and delete keys.
+func (t *terminalReader) escape(e *lineEditor) {
+	b, err := t.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return
+	}
+
+	// read parameter bytes up to the final byte
+	var params strings.Builder
+	for {
+		b, err = t.in.ReadByte()
+		if err != nil {
+			return
+		}
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+		params.WriteByte(b)
+	}
+
+	switch b {
+	case 'A':
+		e.previous()
+	case 'B':
+		e.next()
+	case 'C':
+		e.right()
+	case 'D':
+		e.left()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.line)
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			e.pos = 0
+		case "4", "8":
+			e.pos = len(e.line)
+		case "3":
+			e.delete()
+		}
+	}
+}
+
+// Completes the word before the cursor.  A unique candidate, or the prefix
+// shared by all candidates, is inserted.  When that adds nothing, the
+// candidates are listed below the line along with their hints.
+func (t *terminalReader) completeWord(e *lineEditor) {
+	if t.complete == nil {
+		return
+	}
+
+	word := e.word()
+	completions := t.complete(word)
+	if len(completions) == 0 {
+		return
+	}
+
+	if prefix := commonPrefix(completions); len(prefix) > len(word) {
+		for _, r := range prefix[len(word):] {
+			e.insert(r)
+		}
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\r\n")
+	for i, c := range completions {
+		if i > 0 {
+			sb.WriteString("  ")
+		}
+		if c.Hint != "" {
+			sb.WriteString(c.Hint)
+		} else {
+			sb.WriteString(c.Name)
+		}
+	}
+	sb.WriteString("\r\n")
+
+	fmt.Fprint(t.out, sb.String())
+}
+
+// Redraws the prompt and line, placing the cursor at the editing position.
+func (t *terminalReader) refresh(e *lineEditor) {
+	var sb strings.Builder
+
+	sb.WriteString("\r")
+	sb.WriteString(e.prompt)
+	sb.WriteString(string(e.line))
+	sb.WriteString("\x1b[K\r")
+	if column := len([]rune(e.prompt)) + e.pos; column > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dC", column)
+	}
+
+	fmt.Fprint(t.out, sb.String())
+}
+
+// ----------------------------------------------------------------------------
+// Line editor
+// ----------------------------------------------------------------------------
+
+// lineEditor holds the line being edited and the cursor position within it.
+type lineEditor struct {
+	prompt  string
+	line    []rune
+	pos     int
+	history []string
+	index   int    // position in history; len(history) is the new line
+	pending []rune // the new line, saved while browsing history
+}
+
+func (e *lineEditor) insert(r rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.pos+1:], e.line[e.pos:])
+	e.line[e.pos] = r
+	e.pos++
+}
+
+func (e *lineEditor) backspace() {
+	if e.pos == 0 {
+		return
+	}
+	e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+	e.pos--
+}
+
+func (e *lineEditor) delete() {
+	if e.pos == len(e.line) {
+		return
+	}
+	e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+}
+
+// Deletes the word before the cursor along with any trailing whitespace.
+func (e *lineEditor) deleteWord() {
+	start := e.pos
+	for start > 0 && unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	e.line = append(e.line[:start], e.line[e.pos:]...)
+	e.pos = start
+}
+
+// Returns the identifier, possibly partial, that ends at the cursor.
+func (e *lineEditor) word() string {
+	start := e.pos
+	for start > 0 && isIdentifierRune(e.line[start-1]) {
+		start--
+	}
+	return string(e.line[start:e.pos])
+}
+
+func (e *lineEditor) left() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *lineEditor) right() {
+	if e.pos < len(e.line) {
+		e.pos++
+	}
+}
+
+// Replaces the line with the previous history entry.
+func (e *lineEditor) previous() {
+	if e.index == 0 {
+		return
+	}
+	if e.index == len(e.history) {
+		e.pending = e.line
+	}
+	e.index--
+	e.setLine([]rune(e.history[e.index]))
+}
+
+// Replaces the line with the next history entry, or the new line after the
+// last entry.
+func (e *lineEditor) next() {
+	if e.index == len(e.history) {
+		return
+	}
+	e.index++
+	if e.index == len(e.history) {
+		e.setLine(e.pending)
+		return
+	}
+	e.setLine([]rune(e.history[e.index]))
+}
+
+func (e *lineEditor) setLine(line []rune) {
+	e.line = append([]rune(nil), line...)
+	e.pos = len(e.line)
+}
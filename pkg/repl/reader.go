@@ -0,0 +1,109 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	historyFileName = ".corrosion_history"
+	historyLimit    = 1000
+)
+
+// completer returns the tab completions for the partial word prefix.
+type completer func(prefix string) []completion
+
+// Returns a terminalReader when in is a terminal that supports line editing,
+// otherwise a scannerReader.
+func newLineReader(in io.Reader, out io.Writer, complete completer) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		return newTerminalReader(f, out, historyPath(), complete)
+	}
+	return newScannerReader(in, out)
+}
+
+// ----------------------------------------------------------------------------
+// Scanner reader
+// ----------------------------------------------------------------------------
+
+// scannerReader reads lines without editing support.  It is used when the
+// input is not a terminal, e.g. when input is piped to the REPL.
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func newScannerReader(in io.Reader, out io.Writer) *scannerReader {
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+func (s *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return s.scanner.Text(), nil
+}
+
+func (s *scannerReader) AddHistory(entry string) {}
+func (s *scannerReader) Close() error            { return nil }
+
+// ----------------------------------------------------------------------------
+// History
+// ----------------------------------------------------------------------------
+
+// Returns the path of the history file in the user's home directory, or an
+// empty string if there is no home directory.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFileName)
+}
+
+// Returns the most recent historyLimit entries in the history file at path.
+// The file is rewritten if it holds more entries than that.
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	history := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+		contents := strings.Join(history, "\n") + "\n"
+		os.WriteFile(path, []byte(contents), 0o600)
+	}
+
+	return history
+}
+
+// Appends entry to the history file at path.
+func appendHistory(path, entry string) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, entry)
+}
@@ -0,0 +1,348 @@
+// The repl package implements the interactive read-eval-print loop for the
+// Corrosion language.
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+	"github.com/freddiehaddad/corrosion/pkg/session"
+	"github.com/freddiehaddad/corrosion/pkg/token"
+)
+
+const (
+	appName            = "Corrosion"
+	prompt             = "> "
+	continuationPrompt = ". "
+)
+
+// Returned by a lineReader when the user cancels the current line (Ctrl+C).
+var errInterrupt = errors.New("interrupt")
+
+// lineReader reads a line of input after displaying prompt.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(entry string)
+	Close() error
+}
+
+// ----------------------------------------------------------------------------
+// REPL
+// ----------------------------------------------------------------------------
+
+// The REPL object represents the state of an interactive session.
+type REPL struct {
+	env    *object.Environment
+	reader lineReader
+	out    io.Writer
+}
+
+// Creates and returns a REPL reading from in and writing to out.  When in is a
+// terminal, line editing, tab completion and persistent history are enabled.
+func New(in io.Reader, out io.Writer) *REPL {
+	r := &REPL{
+		env: object.NewEnvironment(),
+		out: out,
+	}
+	r.reader = newLineReader(in, out, r.complete)
+	return r
+}
+
+// Start runs a REPL reading from in and writing to out until the end of input
+// or the :quit command.
+func Start(in io.Reader, out io.Writer) {
+	r := New(in, out)
+	defer r.Close()
+
+	fmt.Fprintln(out, "Welcome to", appName)
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Press Ctrl+D (^D) or type :quit to exit, :help for help")
+
+	r.Run()
+
+	fmt.Fprintln(out, "Exiting", appName)
+}
+
+// Run reads and evaluates entries until the end of input or :quit.
+func (r *REPL) Run() {
+	for {
+		input, err := r.readEntry()
+		if errors.Is(err, errInterrupt) {
+			continue
+		}
+		if err != nil && input == "" {
+			return
+		}
+
+		if isCommand(input) {
+			if quit := r.command(input); quit {
+				return
+			}
+			continue
+		}
+
+		r.evaluate(input)
+	}
+}
+
+// Close releases the resources held by the REPL, restoring the terminal.
+func (r *REPL) Close() error {
+	return r.reader.Close()
+}
+
+// Reads a complete entry.  Additional lines are read with the continuation
+// prompt for as long as the input contains unclosed braces or parentheses.
+func (r *REPL) readEntry() (string, error) {
+	line, err := r.reader.ReadLine(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{line}
+	for !isCommand(line) && !balanced(strings.Join(lines, "\n")) {
+		line, err = r.reader.ReadLine(continuationPrompt)
+		if errors.Is(err, errInterrupt) {
+			return "", err
+		}
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	input := strings.Join(lines, "\n")
+	if strings.TrimSpace(input) != "" {
+		r.reader.AddHistory(strings.Join(lines, " "))
+	}
+
+	return input, err
+}
+
+// Returns the tab completions for prefix in the session environment.
+func (r *REPL) complete(prefix string) []completion {
+	return complete(r.env, prefix)
+}
+
+// Parses and evaluates input, printing the value of each statement.
+func (r *REPL) evaluate(input string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	r.printWarnings(p)
+	if r.checkAndPrintErrors(p) {
+		return
+	}
+
+	for _, statement := range program.Statements {
+		obj := evaluator.Eval(statement, r.env)
+		if obj.Type() != object.NULL_OBJ {
+			fmt.Fprintln(r.out, obj.Inspect())
+		}
+		if obj.Type() == object.ERROR_OBJ {
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Meta-commands
+// ----------------------------------------------------------------------------
+
+const help = `:load file     evaluate the file in the current session
+:env           list the bindings in the session
+:ast expr      print the syntax tree of expr
+:tokens expr   print the tokens of expr
+:reset         discard all bindings
+:save file     save the bindings in the session to file
+:restore file  replace the bindings with those saved in file
+:quit          exit the REPL
+:help          print this message`
+
+// Returns true if input is a meta-command rather than source code.
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ":")
+}
+
+// Executes the meta-command in input.  Returns true if the REPL should exit.
+func (r *REPL) command(input string) bool {
+	name, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q":
+		return true
+	case ":help":
+		fmt.Fprintln(r.out, help)
+	case ":load":
+		r.load(arg)
+	case ":env":
+		r.printEnvironment()
+	case ":ast":
+		r.printAST(arg)
+	case ":tokens":
+		r.printTokens(arg)
+	case ":reset":
+		r.env = object.NewEnvironment()
+	case ":save":
+		r.save(arg)
+	case ":restore":
+		r.restore(arg)
+	default:
+		fmt.Fprintf(r.out, "unknown command %s (try :help)\n", name)
+	}
+
+	return false
+}
+
+// Evaluates the contents of the file at path in the session environment.
+func (r *REPL) load(path string) {
+	if path == "" {
+		fmt.Fprintln(r.out, "usage: :load file")
+		return
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	r.printWarnings(p)
+	if r.checkAndPrintErrors(p) {
+		return
+	}
+
+	if obj := evaluator.Eval(program, r.env); obj != nil &&
+		obj.Type() == object.ERROR_OBJ {
+		fmt.Fprintln(r.out, obj.Inspect())
+	}
+}
+
+// Writes the bindings in the session environment to the file at path.
+func (r *REPL) save(path string) {
+	if path == "" {
+		fmt.Fprintln(r.out, "usage: :save file")
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	defer f.Close()
+
+	if err := session.Save(f, r.env); err != nil {
+		fmt.Fprintln(r.out, err)
+	}
+}
+
+// Replaces the session environment with the one saved in the file at path.
+func (r *REPL) restore(path string) {
+	if path == "" {
+		fmt.Fprintln(r.out, "usage: :restore file")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	defer f.Close()
+
+	env, err := session.Restore(f)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	r.env = env
+}
+
+// Prints the bindings in the session environment.
+func (r *REPL) printEnvironment() {
+	for _, name := range r.env.Names() {
+		obj, _ := r.env.Get(name)
+		fmt.Fprintf(r.out, "%s = %s\n", name, obj.Inspect())
+	}
+}
+
+// Prints the syntax tree of the program in input.
+func (r *REPL) printAST(input string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if r.checkAndPrintErrors(p) {
+		return
+	}
+
+	writeTree(r.out, program)
+}
+
+// Prints the tokens produced by the lexer for input.
+func (r *REPL) printTokens(input string) {
+	l := lexer.New(input)
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			return
+		}
+		fmt.Fprintf(r.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Helpers
+// ----------------------------------------------------------------------------
+
+// Returns true if input has no unclosed braces or parentheses.
+func balanced(input string) bool {
+	depth := 0
+
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LPAREN:
+			depth++
+		case token.RBRACE, token.RPAREN:
+			depth--
+		}
+	}
+
+	return depth <= 0
+}
+
+// Prints any parser warnings.
+func (r *REPL) printWarnings(p *parser.Parser) {
+	for _, warning := range p.Warnings() {
+		fmt.Fprintf(r.out, "warning: %s\n", warning)
+	}
+}
+
+// Returns true if there were any parser errors.
+func (r *REPL) checkAndPrintErrors(p *parser.Parser) bool {
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return false
+	}
+
+	fmt.Fprintf(r.out, "ParseProgram returned %d errors\n", len(errors))
+	for index, error := range errors {
+		fmt.Fprintf(r.out, "errors[%d]: %s\n", index, error)
+	}
+
+	return true
+}
@@ -0,0 +1,273 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/object"
+)
+
+// Runs the REPL over input and returns everything written to the output with
+// the prompts removed.
+func run(t *testing.T, input string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	r := New(strings.NewReader(input), &out)
+	r.Run()
+	r.Close()
+
+	output := strings.ReplaceAll(out.String(), prompt, "")
+	return strings.ReplaceAll(output, continuationPrompt, "")
+}
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"var x = 1;", true},
+		{"func f() {", false},
+		{"func f() {\n return 1;\n}", true},
+		{"f(1,", false},
+		{"f(1,\n2);", true},
+		{"{ { }", false},
+		{"}", true},
+	}
+
+	for index, test := range tests {
+		if balanced(test.input) != test.expected {
+			t.Errorf("tests[%d]: balanced(%q) expected=%t",
+				index, test.input, test.expected)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "func add(a, b) {\n  return a + b;\n}\nadd(2,\n3);\n"
+
+	output := run(t, input)
+	if output != "5\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+}
+
+func TestReturnedCallIsMade(t *testing.T) {
+	output := run(t, "func g() { return 7; }\nreturn g();\n")
+	if output != "7\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+}
+
+func TestEvaluationStopsAtError(t *testing.T) {
+	output := run(t, "x; 5;\n")
+	expected := "ERROR: undefined identifier=\"x\" (x)\n"
+	if output != expected {
+		t.Errorf("unexpected output. expected=%q got=%q",
+			expected, output)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":env\n", ""},
+		{"var b = 2; var a = 1;\n:env\n", "a = 1\nb = 2\n"},
+		{"var a = 1;\n:reset\n:env\na;\n",
+			"ERROR: undefined identifier=\"a\" (a)\n"},
+		{":quit\n5;\n", ""},
+		{":tokens x += 1\n",
+			"IDENT      \"x\"\n+=         \"+=\"\nINTEGER    \"1\"\n"},
+		{":ast -1;\n", "Program\n" +
+			"  Statements[0]: ExpressionStatement\n" +
+			"    Expression: PrefixExpression\n" +
+			"      Right: IntegerLiteral\n" +
+			"        Value: 1\n" +
+			"      Operator: -\n"},
+		{":ast x;\n", "Program\n" +
+			"  Statements[0]: ExpressionStatement\n" +
+			"    Expression: Identifier\n" +
+			"      Value: x\n"},
+		{":bogus\n", "unknown command :bogus (try :help)\n"},
+		{":load\n", "usage: :load file\n"},
+	}
+
+	for index, test := range tests {
+		output := run(t, test.input)
+		if output != test.expected {
+			t.Errorf("tests[%d]: unexpected output. expected=%q got=%q",
+				index, test.expected, output)
+		}
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.cr")
+	source := "var x = 20;\nfunc double(n) {\n  return n * 2;\n}\n"
+	if err := os.WriteFile(path, []byte(source), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := run(t, ":load "+path+"\ndouble(x);\n")
+	if output != "40\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+}
+
+func TestSaveAndRestoreCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	run(t, "var x = 20;\nfunc double(n) { return n * 2; }\n:save "+path+"\n")
+
+	output := run(t, ":restore "+path+"\ndouble(x);\n")
+	if output != "40\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+
+	output = run(t, ":save\n:restore\n")
+	if output != "usage: :save file\nusage: :restore file\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	e := &lineEditor{history: []string{"first", "second"}, index: 2}
+
+	for _, r := range "hello world" {
+		e.insert(r)
+	}
+	e.deleteWord()
+	if string(e.line) != "hello " {
+		t.Errorf("deleteWord: got=%q", string(e.line))
+	}
+
+	e.left()
+	e.left()
+	e.backspace()
+	e.insert('X')
+	e.delete()
+	if string(e.line) != "helX " || e.pos != 4 {
+		t.Errorf("editing: got=%q pos=%d", string(e.line), e.pos)
+	}
+
+	e.previous()
+	if string(e.line) != "second" {
+		t.Errorf("previous: got=%q", string(e.line))
+	}
+	e.previous()
+	e.previous()
+	if string(e.line) != "first" {
+		t.Errorf("previous: got=%q", string(e.line))
+	}
+	e.next()
+	e.next()
+	if string(e.line) != "helX " {
+		t.Errorf("next: got=%q", string(e.line))
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+
+	for i := 0; i < historyLimit+5; i++ {
+		appendHistory(path, strings.Repeat("x", i%3+1))
+	}
+
+	history := loadHistory(path)
+	if len(history) != historyLimit {
+		t.Errorf("expected %d entries got=%d", historyLimit, len(history))
+	}
+
+	if reloaded := loadHistory(path); len(reloaded) != historyLimit {
+		t.Errorf("history file not trimmed. got=%d", len(reloaded))
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := New(strings.NewReader(""), io.Discard)
+	r.evaluate("var value = 1; func add(a, b) { return a + b; }")
+	r.evaluate("var inner = 0;")
+
+	tests := []struct {
+		prefix   string
+		expected []completion
+	}{
+		{"va", []completion{{Name: "value"}, {Name: "var"}}},
+		{"ad", []completion{{Name: "add", Hint: "add(a, b)"}}},
+		{"i", []completion{{Name: "if"}, {Name: "inner"}}},
+		{"re", []completion{{Name: "return"}}},
+		{"zz", nil},
+	}
+
+	for index, test := range tests {
+		completions := r.complete(test.prefix)
+		if !reflect.DeepEqual(completions, test.expected) {
+			t.Errorf("tests[%d]: complete(%q) expected=%v got=%v",
+				index, test.prefix, test.expected, completions)
+		}
+	}
+}
+
+func TestCompleteOuterScopes(t *testing.T) {
+	global := object.NewEnvironment()
+	global.Set("total", &object.Integer{Value: 1})
+	global.Set("tally", &object.Integer{Value: 2})
+
+	local := object.NewScopedEnvironment(global)
+	local.Set("total", &object.Function{})
+
+	expected := []completion{
+		{Name: "tally"},
+		{Name: "total", Hint: "total()"},
+		{Name: "true"},
+	}
+
+	completions := complete(local, "t")
+	if !reflect.DeepEqual(completions, expected) {
+		t.Errorf("expected=%v got=%v", expected, completions)
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	candidates := []completion{
+		{Name: "counter"},
+		{Name: "count", Hint: "count(n)"},
+	}
+
+	var out bytes.Buffer
+	reader := &terminalReader{
+		out: &out,
+		complete: func(prefix string) []completion {
+			var matches []completion
+			for _, c := range candidates {
+				if strings.HasPrefix(c.Name, prefix) {
+					matches = append(matches, c)
+				}
+			}
+			return matches
+		},
+	}
+
+	e := &lineEditor{}
+	for _, r := range "1 + co" {
+		e.insert(r)
+	}
+
+	reader.completeWord(e)
+	if string(e.line) != "1 + count" || out.Len() != 0 {
+		t.Errorf("common prefix: got=%q output=%q", string(e.line), out.String())
+	}
+
+	reader.completeWord(e)
+	if output := out.String(); output != "\r\ncounter  count(n)\r\n" {
+		t.Errorf("candidates: got=%q", output)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// Line editing is only supported on Linux.  Elsewhere the REPL falls back to
+// reading plain lines.
+
+type terminalState struct{}
+
+var errUnsupported = errors.New("line editing not supported")
+
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (*terminalState, error) { return nil, errUnsupported }
+
+func restoreTerminal(fd int, state *terminalState) error {
+	return errUnsupported
+}
@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/token"
+)
+
+var (
+	tokenType  = reflect.TypeOf(token.Token{})
+	bigIntType = reflect.TypeOf(&big.Int{})
+)
+
+// Writes node to w as an indented tree, one field per line.
+func writeTree(w io.Writer, node ast.Node) {
+	writeValue(w, "", reflect.ValueOf(node), 0)
+}
+
+func writeValue(w io.Writer, label string, v reflect.Value, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			fmt.Fprintf(w, "%s%s<nil>\n", indent, label)
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == bigIntType {
+		fmt.Fprintf(w, "%s%s%s\n", indent, label, v.Interface())
+		return
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			fmt.Fprintf(w, "%s%s<nil>\n", indent, label)
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fmt.Fprintf(w, "%s%s%s\n", indent, label, v.Type().Name())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.Type == tokenType || !field.IsExported() ||
+				field.Tag.Get("ast") == "-" {
+				continue
+			}
+			writeValue(w, field.Name+": ", v.Field(i), depth+1)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			fmt.Fprintf(w, "%s%s[]\n", indent, label)
+		}
+		name := strings.TrimSuffix(label, ": ")
+		for i := 0; i < v.Len(); i++ {
+			element := fmt.Sprintf("%s[%d]: ", name, i)
+			writeValue(w, element, v.Index(i), depth)
+		}
+	default:
+		fmt.Fprintf(w, "%s%s%v\n", indent, label, v.Interface())
+	}
+}
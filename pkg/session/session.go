@@ -0,0 +1,289 @@
+// The session package saves the bindings of an environment to JSON and
+// restores them, so that the state built up in an interactive session can be
+// picked up again later.
+//
+// Integers, booleans and null are saved as values.  Functions are saved as
+// the source of their declaration along with the environment they close over.
+// Every environment reachable from the saved one, through outer scopes or
+// function closures, is written once and shared on restore, so a closure over
+// the global environment sees the restored globals.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+// Version of the session format written by Save.
+const Version = 1
+
+// Returned by Restore when the input is not a valid session.
+var ErrInvalidSession = errors.New("invalid session")
+
+// Top level of a session file.  Scopes[0] is the saved environment.
+type session struct {
+	Version int     `json:"version"`
+	Scopes  []scope `json:"scopes"`
+}
+
+// An environment.  Outer is the index of the enclosing scope, or nil.
+type scope struct {
+	Outer    *int      `json:"outer,omitempty"`
+	Bindings []binding `json:"bindings"`
+}
+
+type binding struct {
+	Name     string `json:"name"`
+	Constant bool   `json:"constant,omitempty"`
+	Value    value  `json:"value"`
+}
+
+// A saved object.  Value holds integers and booleans, Source and Scope hold
+// the declaration and closure of a function.
+type value struct {
+	Type   object.ObjectType `json:"type"`
+	Value  json.RawMessage   `json:"value,omitempty"`
+	Source string            `json:"source,omitempty"`
+	Scope  int               `json:"scope,omitempty"`
+}
+
+// ----------------------------------------------------------------------------
+// Save
+// ----------------------------------------------------------------------------
+
+// Save writes the bindings in env, and every environment they depend on, to w
+// as JSON.
+func Save(w io.Writer, env *object.Environment) error {
+	e := &encoder{index: make(map[*object.Environment]int)}
+	if _, err := e.scope(env); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(session{Version: Version, Scopes: e.scopes})
+}
+
+type encoder struct {
+	scopes []scope
+	index  map[*object.Environment]int
+}
+
+// Returns the index of env in the session, adding it and its outer scopes
+// when seen for the first time.
+func (e *encoder) scope(env *object.Environment) (int, error) {
+	if i, ok := e.index[env]; ok {
+		return i, nil
+	}
+
+	i := len(e.scopes)
+	e.index[env] = i
+	e.scopes = append(e.scopes, scope{})
+
+	if env.Outer() != nil {
+		outer, err := e.scope(env.Outer())
+		if err != nil {
+			return 0, err
+		}
+		e.scopes[i].Outer = &outer
+	}
+
+	bindings := []binding{}
+	for _, name := range env.Names() {
+		obj, _ := env.GetLocal(name)
+		v, err := e.value(name, obj)
+		if err != nil {
+			return 0, err
+		}
+		bindings = append(bindings, binding{
+			Name:     name,
+			Constant: env.IsConstant(name),
+			Value:    v,
+		})
+	}
+	e.scopes[i].Bindings = bindings
+
+	return i, nil
+}
+
+func (e *encoder) value(name string, obj object.Object) (value, error) {
+	v := value{Type: obj.Type()}
+
+	var err error
+	switch obj := obj.(type) {
+	case *object.Integer:
+		v.Value, err = json.Marshal(obj.Value)
+	case *object.BigInt:
+		v.Value, err = json.Marshal(obj.Value.String())
+	case *object.Boolean:
+		v.Value, err = json.Marshal(obj.Value)
+	case *object.Null:
+	case *object.Function:
+		var sb strings.Builder
+		writeFunction(&sb, name, obj.Parameters, obj.Body)
+		v.Source = sb.String()
+		v.Scope, err = e.scope(obj.Env)
+	default:
+		err = fmt.Errorf("cannot save %q of type %s", name, obj.Type())
+	}
+
+	return v, err
+}
+
+// ----------------------------------------------------------------------------
+// Restore
+// ----------------------------------------------------------------------------
+
+// Restore reads a session written by Save from r and returns the environment
+// that was saved, with its bindings re-established.
+func Restore(r io.Reader) (*object.Environment, error) {
+	var s session
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSession, err)
+	}
+	if s.Version != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d",
+			ErrInvalidSession, s.Version)
+	}
+	if len(s.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scopes", ErrInvalidSession)
+	}
+
+	d := &decoder{
+		scopes: s.Scopes,
+		envs:   make([]*object.Environment, len(s.Scopes)),
+	}
+
+	for i := range s.Scopes {
+		if _, err := d.environment(i, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, scope := range s.Scopes {
+		for _, b := range scope.Bindings {
+			obj, err := d.object(b.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %v",
+					ErrInvalidSession, b.Name, err)
+			}
+			if b.Constant {
+				d.envs[i].SetConstant(b.Name, obj)
+			} else {
+				d.envs[i].Set(b.Name, obj)
+			}
+		}
+	}
+
+	return d.envs[0], nil
+}
+
+type decoder struct {
+	scopes []scope
+	envs   []*object.Environment
+}
+
+// Returns the environment for scope i, creating it and its outer scopes if
+// needed.  depth guards against outer scopes that form a cycle.
+func (d *decoder) environment(i, depth int) (*object.Environment, error) {
+	if i < 0 || i >= len(d.scopes) || depth > len(d.scopes) {
+		return nil, fmt.Errorf("%w: bad scope %d", ErrInvalidSession, i)
+	}
+	if d.envs[i] != nil {
+		return d.envs[i], nil
+	}
+
+	if d.scopes[i].Outer == nil {
+		d.envs[i] = object.NewEnvironment()
+		return d.envs[i], nil
+	}
+
+	outer, err := d.environment(*d.scopes[i].Outer, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	d.envs[i] = object.NewScopedEnvironment(outer)
+
+	return d.envs[i], nil
+}
+
+func (d *decoder) object(v value) (object.Object, error) {
+	switch v.Type {
+	case object.INTEGER_OBJ:
+		var i int64
+		if err := json.Unmarshal(v.Value, &i); err != nil {
+			return nil, err
+		}
+		return &object.Integer{Value: i}, nil
+
+	case object.BIGINT_OBJ:
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("bad integer %q", s)
+		}
+		return &object.BigInt{Value: i}, nil
+
+	case object.BOOLEAN_OBJ:
+		var b bool
+		if err := json.Unmarshal(v.Value, &b); err != nil {
+			return nil, err
+		}
+		if b {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case object.NULL_OBJ:
+		return evaluator.NULL, nil
+
+	case object.FUNCTION_OBJ:
+		return d.function(v)
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", v.Type)
+}
+
+// Parses the declaration in v and returns the function closing over the
+// restored scope.
+func (d *decoder) function(v value) (object.Object, error) {
+	if v.Scope < 0 || v.Scope >= len(d.envs) {
+		return nil, fmt.Errorf("bad scope %d", v.Scope)
+	}
+
+	l := lexer.New(v.Source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("parsing %q: %s", v.Source, errs[0])
+	}
+
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("not a function declaration: %q", v.Source)
+	}
+	fds, ok := program.Statements[0].(*ast.FunctionDeclarationStatement)
+	if !ok {
+		return nil, fmt.Errorf("not a function declaration: %q", v.Source)
+	}
+
+	return &object.Function{
+		Name:       fds.Name.Value,
+		Parameters: fds.Parameters,
+		Body:       fds.Body,
+		Env:        d.envs[v.Scope],
+	}, nil
+}
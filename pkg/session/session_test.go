@@ -0,0 +1,163 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/freddiehaddad/corrosion/pkg/evaluator"
+	"github.com/freddiehaddad/corrosion/pkg/lexer"
+	"github.com/freddiehaddad/corrosion/pkg/object"
+	"github.com/freddiehaddad/corrosion/pkg/parser"
+)
+
+func evaluate(t *testing.T, input string, env *object.Environment) object.Object {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors for %q: %v", input, errs)
+	}
+
+	return evaluator.Eval(program, env)
+}
+
+// Saves env and returns the restored environment.
+func roundTrip(t *testing.T, env *object.Environment) *object.Environment {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Save(&buf, env); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored, err := Restore(&buf)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	return restored
+}
+
+func TestSaveRestore(t *testing.T) {
+	env := object.NewEnvironment()
+	evaluate(t, `
+		var count = 3;
+		var huge = 9223372036854775807 + 1;
+		var flag = true;
+		const limit = 10;
+		func double(n) { return n * 2; }
+		func next() { count += 1; return count; }
+		func adder(x) {
+			func add(y) { return x + y; }
+			return add;
+		}
+		var addFive = adder(5);
+		func sign(n) {
+			return match (n) { 0 => 0, m if m < 0 => -1, _ => 1 };
+		}
+		func pick(c) { return if (c) { 1 } else { 2 }; }
+	`, env)
+
+	restored := roundTrip(t, env)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"count;", "3"},
+		{"huge;", "9223372036854775808"},
+		{"flag;", "true"},
+		{"limit;", "10"},
+		{"double(21);", "42"},
+		{"addFive(2);", "7"},
+		{"sign(-4);", "-1"},
+		{"pick(false);", "2"},
+		// closures over the globals see the restored globals
+		{"next(); count;", "4"},
+		{"limit = 1;", "ERROR: cannot assign to constant \"limit\""},
+	}
+
+	for index, test := range tests {
+		obj := evaluate(t, test.input, restored)
+		if obj.Inspect() != test.expected {
+			t.Errorf("tests[%d]: %q expected=%s got=%s",
+				index, test.input, test.expected, obj.Inspect())
+		}
+	}
+
+	// the original session is unaffected
+	if obj := evaluate(t, "count;", env); obj.Inspect() != "3" {
+		t.Errorf("original environment changed. count=%s", obj.Inspect())
+	}
+}
+
+func TestRestoreSharesScopes(t *testing.T) {
+	env := object.NewEnvironment()
+	evaluate(t, `
+		func counter() {
+			var n = 0;
+			func increment() { n += 1; return n; }
+			return increment;
+		}
+		var tick = counter();
+		var tock = tick;
+	`, env)
+
+	restored := roundTrip(t, env)
+
+	evaluate(t, "tick(); tick();", restored)
+	if obj := evaluate(t, "tock();", restored); obj.Inspect() != "3" {
+		t.Errorf("closures do not share their scope. got=%s", obj.Inspect())
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`{"version": 99, "scopes": [{"bindings": []}]}`,
+		`{"version": 1, "scopes": []}`,
+		`{"version": 1, "scopes": [{"outer": 1, "bindings": []},
+			{"outer": 0, "bindings": []}]}`,
+		`{"version": 1, "scopes": [{"bindings": [
+			{"name": "x", "value": {"type": "STRING", "value": "a"}}]}]}`,
+		`{"version": 1, "scopes": [{"bindings": [
+			{"name": "f", "value": {"type": "FUNCTION", "source": "1 +"}}]}]}`,
+	}
+
+	for index, test := range tests {
+		_, err := Restore(strings.NewReader(test))
+		if !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("tests[%d]: expected ErrInvalidSession. got=%v",
+				index, err)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := []string{
+		"func f(a, b) { var c = (a + b); return (c * 2); }",
+		"func f() { if ((1 < 2)) { 1; } else { 2; } }",
+		"func f(x) { const y = if (x) { 1; } else { 2; }; return y; }",
+		"func f(n) { return match (n) { -1 => 0, m if (m > 0) => { m; }, _ => 1 }; }",
+		"func f(x) { (x += 1); (x++); (--x); return (x ? 1 : (-2)); }",
+		"func f() { { var inner = g(1, h()); } }",
+	}
+
+	for index, test := range tests {
+		l := lexer.New(test)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Fatalf("tests[%d]: parse errors: %v", index, errs)
+		}
+
+		output := source(program.Statements[0])
+		if output != test {
+			t.Errorf("tests[%d]: expected=%q got=%q", index, test, output)
+		}
+	}
+}
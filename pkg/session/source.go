@@ -0,0 +1,166 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/freddiehaddad/corrosion/pkg/ast"
+)
+
+// The String methods of the ast nodes omit braces and parentheses, so they
+// cannot be parsed again.  The functions here print nodes as source code that
+// parses back into the same tree.
+
+// Returns the source code for node.
+func source(node ast.Node) string {
+	var sb strings.Builder
+	writeSource(&sb, node)
+	return sb.String()
+}
+
+func writeSource(sb *strings.Builder, node ast.Node) {
+	switch node := node.(type) {
+	// statements
+	case *ast.BlockStatement:
+		writeBlock(sb, node)
+	case *ast.ExpressionStatement:
+		writeSource(sb, node.Expression)
+		sb.WriteByte(';')
+	case *ast.FunctionDeclarationStatement:
+		writeFunction(sb, node.Name.Value, node.Parameters, node.Body)
+	case *ast.ReturnStatement:
+		sb.WriteString("return ")
+		writeSource(sb, node.ReturnValue)
+		sb.WriteByte(';')
+	case *ast.VariableDeclarationStatement:
+		sb.WriteString(node.TokenLiteral())
+		sb.WriteByte(' ')
+		sb.WriteString(node.Name.Value)
+		sb.WriteString(" = ")
+		writeSource(sb, node.Value)
+		sb.WriteByte(';')
+
+	// expressions
+	case *ast.IfStatement:
+		sb.WriteString("if (")
+		writeSource(sb, node.Condition)
+		sb.WriteString(") ")
+		writeSource(sb, node.Consequence)
+		if node.Alternative != nil {
+			sb.WriteString(" else ")
+			writeSource(sb, node.Alternative)
+		}
+	case *ast.AssignmentExpression:
+		writeInfix(sb, node.Left, node.Operator, node.Right)
+	case *ast.InfixExpression:
+		writeInfix(sb, node.Left, node.Operator, node.Right)
+	case *ast.ConditionalExpression:
+		sb.WriteByte('(')
+		writeSource(sb, node.Condition)
+		sb.WriteString(" ? ")
+		writeSource(sb, node.Consequence)
+		sb.WriteString(" : ")
+		writeSource(sb, node.Alternative)
+		sb.WriteByte(')')
+	case *ast.PrefixExpression:
+		sb.WriteByte('(')
+		sb.WriteString(node.Operator)
+		writeSource(sb, node.Right)
+		sb.WriteByte(')')
+	case *ast.PostfixExpression:
+		sb.WriteByte('(')
+		writeSource(sb, node.Left)
+		sb.WriteString(node.Operator)
+		sb.WriteByte(')')
+	case *ast.FunctionCallExpression:
+		writeSource(sb, node.Function)
+		sb.WriteByte('(')
+		for i, arg := range node.Arguments {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeSource(sb, arg)
+		}
+		sb.WriteByte(')')
+	case *ast.MatchExpression:
+		writeMatch(sb, node)
+
+	// identifiers and literals print as they were written
+	default:
+		sb.WriteString(node.String())
+	}
+}
+
+func writeBlock(sb *strings.Builder, block *ast.BlockStatement) {
+	sb.WriteByte('{')
+	for _, s := range block.Statements {
+		sb.WriteByte(' ')
+		writeSource(sb, s)
+	}
+	sb.WriteString(" }")
+}
+
+func writeFunction(
+	sb *strings.Builder,
+	name string,
+	parameters []ast.Identifier,
+	body ast.Statement,
+) {
+	sb.WriteString("func ")
+	sb.WriteString(name)
+	sb.WriteByte('(')
+	for i, p := range parameters {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(p.Value)
+	}
+	sb.WriteString(") ")
+	writeSource(sb, body)
+}
+
+func writeInfix(
+	sb *strings.Builder, left ast.Expression, op string, right ast.Expression,
+) {
+	sb.WriteByte('(')
+	writeSource(sb, left)
+	sb.WriteByte(' ')
+	sb.WriteString(op)
+	sb.WriteByte(' ')
+	writeSource(sb, right)
+	sb.WriteByte(')')
+}
+
+func writeMatch(sb *strings.Builder, node *ast.MatchExpression) {
+	sb.WriteString("match (")
+	writeSource(sb, node.Subject)
+	sb.WriteString(") {")
+
+	for i, arm := range node.Arms {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte(' ')
+
+		// negative literal patterns are written without parentheses
+		if prefix, ok := arm.Pattern.(*ast.PrefixExpression); ok {
+			sb.WriteString(prefix.Operator)
+			writeSource(sb, prefix.Right)
+		} else {
+			writeSource(sb, arm.Pattern)
+		}
+
+		if arm.Guard != nil {
+			sb.WriteString(" if ")
+			writeSource(sb, arm.Guard)
+		}
+		sb.WriteString(" => ")
+
+		if body, ok := arm.Body.(*ast.ExpressionStatement); ok {
+			writeSource(sb, body.Expression)
+		} else {
+			writeSource(sb, arm.Body)
+		}
+	}
+
+	sb.WriteString(" }")
+}
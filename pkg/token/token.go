@@ -2,11 +2,15 @@
 // language.
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // line of the first character, starting at 1
+	Column  int // column of the first character, starting at 1
 }
 
 // Tokens
@@ -18,6 +22,10 @@ const (
 	LBRACE    = "{"
 	RBRACE    = "}"
 	COMMA     = ","
+	QUESTION  = "?"
+	COLON     = ":"
+	ARROW     = "=>"
+	WILDCARD  = "_"
 
 	// operators
 	ASSIGN   = "="
@@ -25,9 +33,30 @@ const (
 	PLUS     = "+"
 	DIVIDE   = "/"
 	MULTIPLY = "*"
+	MODULO   = "%"
+	EXPONENT = "**"
+
+	// assignment operators
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	MULTIPLY_ASSIGN = "*="
+	DIVIDE_ASSIGN   = "/="
+	MODULO_ASSIGN   = "%="
+
+	// increment and decrement operators
+	INCREMENT = "++"
+	DECREMENT = "--"
+
+	// bitwise operators
+	BITWISE_AND = "&"
+	BITWISE_OR  = "|"
+	BITWISE_XOR = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
 
 	// prefix only operators
-	BANG = "!"
+	BANG        = "!"
+	BITWISE_NOT = "~"
 
 	// logical operators
 	EQ       = "=="
@@ -36,13 +65,17 @@ const (
 	LT_EQUAL = "<="
 	GT       = ">"
 	GT_EQUAL = ">="
+	AND      = "&&"
+	OR       = "||"
 
 	// keywords
 	VAR    = "VAR"
+	CONST  = "CONST"
 	FUNC   = "FUNC"
 	RETURN = "RETURN"
 	IF     = "IF"
 	ELSE   = "ELSE"
+	MATCH  = "MATCH"
 
 	TRUE  = "TRUE"
 	FALSE = "FALSE"
@@ -53,7 +86,7 @@ const (
 
 	// end of input
 	EOF       = "EOF"
-	EOF_VALUE = byte(0)
+	EOF_VALUE = rune(0)
 
 	// unsupported input
 	ILLEGAL = "ILLEGAL"
@@ -62,14 +95,26 @@ const (
 // Language keywords.
 var keywords = map[string]TokenType{
 	"var":    VAR,
+	"const":  CONST,
 	"func":   FUNC,
 	"if":     IF,
 	"else":   ELSE,
+	"match":  MATCH,
 	"return": RETURN,
 	"true":   TRUE,
 	"false":  FALSE,
 }
 
+// Returns the language keywords in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
 // Checks if tt is in the keyword table and return the corresponding TokenType.
 // Otherwise, IDENT is returned.
 func LookupType(tt string) TokenType {